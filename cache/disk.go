@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Entry is a cached API response along with its validators
+type Entry struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// Link is the pagination header of the response
+	Link string `json:"link,omitempty"`
+	Body []byte `json:"body"`
+}
+
+// Disk is an on-disk response cache keyed by the strings returned by Key,
+// each entry is stored in its own file named by the hash of the key
+type Disk struct {
+	dir string
+}
+
+// NewDisk returns a Disk cache storing its entries in dir
+func NewDisk(dir string) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create cache dir: %v", err.Error())
+	}
+
+	return &Disk{dir: dir}, nil
+}
+
+// Key returns the cache key of a response to url requested for account with
+// the accept media type, the responses depend on both
+func Key(account, accept, url string) string {
+	return account + "\n" + accept + "\n" + url
+}
+
+func (d *Disk) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get returns the entry cached for key, nil if there is none
+func (d *Disk) Get(key string) *Entry {
+	raw, err := ioutil.ReadFile(d.path(key))
+	if err != nil {
+		return nil
+	}
+
+	entry := &Entry{}
+	if err := json.Unmarshal(raw, entry); err != nil {
+		return nil
+	}
+
+	return entry
+}
+
+// Set caches entry for key, the file is replaced atomically
+// so that concurrent readers never see a partial entry
+func (d *Disk) Set(key string, entry *Entry) error {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(d.dir, "tmp-")
+	if err != nil {
+		return fmt.Errorf("create cache file: %v", err.Error())
+	}
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write cache file: %v", err.Error())
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write cache file: %v", err.Error())
+	}
+
+	return os.Rename(tmp.Name(), d.path(key))
+}
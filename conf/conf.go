@@ -3,23 +3,103 @@ package conf
 import (
 	"log"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 
+	"github.com/knishioka/github-pr-stats/transport"
 	"github.com/subosito/gotenv"
 )
 
 // Configuration specifies env variables
 type Configuration struct {
-	AppID          string
-	GithubKey      string
-	AccountName    string
-	StartDate      string
-	EndDate        string
+	// AuthMode is either app, to authenticate as a Github App
+	// installation, or token to use a static Token
+	AuthMode string
+	// Token is a personal access token or the GITHUB_TOKEN of Github Actions
+	Token     string
+	AppID     string
+	GithubKey string
+	// AccountNames are the orgs to collect the stats of,
+	// ACCOUNT_NAME is a comma separated list
+	AccountNames []string
+	StartDate    string
+	EndDate      string
+	// InstallationID of the Github App, looked up from the
+	// account name if not set
 	InstallationID int64
+	// AllInstallations runs over every account the Github App
+	// is installed on, instead of the AccountNames only
+	AllInstallations bool
 	// Base is #days before the StartDate before which we
 	// want to ignore the PRs
 	Base int
+	// Concurrency is the max number of PRs fetched in parallel
+	Concurrency int
+	// MaxRetries is the max number of retries of a rate limited or failed request
+	MaxRetries int
+	// CacheDir is where API responses are cached for conditional
+	// requests, the cache is disabled if empty
+	CacheDir string
+	// StorePath is the local database the fetched repos, members, teams
+	// & PRs are saved in to only fetch the new ones, no store if empty
+	StorePath string
+	// Offline generates the reports from the store at StorePath
+	// without calling the Github API
+	Offline bool
+	// Backend is the Github API used to get the PRs: rest or graphql
+	Backend string
+	// APIBaseURL is the root of the Github REST API, for Github
+	// Enterprise Server it includes the /api/v3 prefix
+	APIBaseURL string
+	// CABundle is a PEM file of extra CAs to trust
+	CABundle string
+	// ExportFormats are the formats the stats are exported in,
+	// EXPORT_FORMAT is a comma separated list of csv, xlsx, json, ndjson & dot
+	ExportFormats []string
+	// MatrixApprovalsOnly counts only the approvals in the reviewer to author matrix
+	MatrixApprovalsOnly bool
+	// Bucket splits the window into day, week or month buckets,
+	// no bucketing if empty
+	Bucket string
+	// BucketFormat is the layout of the bucketed stats: long or wide
+	BucketFormat string
+	// Compare reports the change of each user's stats from the previous
+	// window of the same length, or from the baseline window when set
+	Compare           bool
+	BaselineStartDate string
+	BaselineEndDate   string
+	// SizeThresholds are the max lines changed of the XS, S, M & L PR size
+	// classes, SIZE_THRESHOLDS is a comma separated list of 4 increasing numbers,
+	// nil for the engine's DefaultSizeThresholds
+	SizeThresholds []int
+	// ExcludeLogins are logins or login patterns of the users to leave out of
+	// the stats, EXCLUDE_LOGINS is a comma separated list, e.g. renovate*,ci-bot.
+	// App logins end in [bot] with the rest Backend only, e.g. dependabot[bot]
+	// is dependabot with graphql, a pattern like dependabot* matches both
+	ExcludeLogins []string
+	// ExcludeBots leaves out the Github Apps & bots, e.g. dependabot[bot]
+	ExcludeBots bool
+	// Repos are the names of the repos to get instead of every repo of the org
+	Repos []string
+	// RepoInclude & RepoExclude are repo name patterns, e.g. api-*
+	RepoInclude []string
+	RepoExclude []string
+	// SkipArchived & SkipForks leave out the archived & the forked repos
+	SkipArchived bool
+	SkipForks    bool
+	// RepoTopics keeps the repos having at least one of the topics
+	RepoTopics []string
+	// RepoVisibilities keeps the public, private or internal repos
+	RepoVisibilities []string
+	// SkipComments doesn't get the comments of the PRs, saving the calls
+	// they take, the comment stats are then zero
+	SkipComments bool
+	// Teams gets the teams of each org to roll the stats up per team
+	Teams bool
+	// TeamAttribution counts a user in several teams in all of them
+	// or only in the first one by slug: all or first
+	TeamAttribution string
 }
 
 var (
@@ -29,23 +109,80 @@ var (
 
 // InitConfigs loads enviornment variables
 func InitConfigs() {
-	if err := gotenv.Load(); err != nil {
-		log.Fatalf("gotenv: could not find .env file - Error: %v\n", err)
+	// the .env file is optional, e.g. Github Actions set the env directly
+	if err := gotenv.Load(); err != nil && !os.IsNotExist(err) {
+		log.Fatalf("gotenv: could not load .env file - Error: %v\n", err)
 	}
 
 	Configs = &Configuration{
-		AppID:       os.Getenv("GITHUB_APP_ID"),
-		GithubKey:   os.Getenv("GITHUB_APP_PRIVATE_KEY"),
-		AccountName: os.Getenv("ACCOUNT_NAME"),
-		StartDate:   os.Getenv("START_DATE"),
-		EndDate:     os.Getenv("END_DATE"),
+		AuthMode:     strings.ToLower(strings.TrimSpace(os.Getenv("AUTH_MODE"))),
+		Token:        strings.TrimSpace(os.Getenv("GITHUB_TOKEN")),
+		AppID:        os.Getenv("GITHUB_APP_ID"),
+		GithubKey:    os.Getenv("GITHUB_APP_PRIVATE_KEY"),
+		AccountNames: getList("ACCOUNT_NAME"),
+		StartDate:    os.Getenv("START_DATE"),
+		EndDate:      os.Getenv("END_DATE"),
+		CacheDir:     strings.TrimSpace(os.Getenv("CACHE_DIR")),
+		StorePath:    strings.TrimSpace(os.Getenv("STORE_PATH")),
+		Backend:      strings.ToLower(strings.TrimSpace(os.Getenv("BACKEND"))),
+		APIBaseURL:   strings.TrimSuffix(strings.TrimSpace(os.Getenv("API_BASE_URL")), "/"),
+		CABundle:     strings.TrimSpace(os.Getenv("CA_BUNDLE")),
 	}
 
-	insID, err := strconv.Atoi(os.Getenv("INSTALLATION_ID"))
-	if err != nil {
-		log.Fatalf("invalid installation id : %v", os.Getenv("INSTALLATION_ID"))
+	Configs.ExportFormats = getList("EXPORT_FORMAT")
+	if len(Configs.ExportFormats) == 0 {
+		Configs.ExportFormats = []string{"csv"}
+	}
+
+	if Configs.APIBaseURL == "" {
+		Configs.APIBaseURL = transport.DefaultBaseURL
+	}
+
+	switch Configs.Backend {
+	case "":
+		Configs.Backend = "rest"
+	case "rest", "graphql":
+	default:
+		log.Fatalf("invalid variable, Backend : %v", Configs.Backend)
+	}
+
+	Configs.Offline = getBool("OFFLINE", false)
+	if Configs.Offline && Configs.StorePath == "" {
+		log.Fatalf("STORE_PATH is required with OFFLINE")
+	}
+
+	if len(Configs.AccountNames) == 0 {
+		// set by Github Actions
+		Configs.AccountNames = getList("GITHUB_REPOSITORY_OWNER")
+	}
+
+	switch Configs.AuthMode {
+	case "":
+		Configs.AuthMode = "app"
+		if Configs.Token != "" && Configs.AppID == "" {
+			Configs.AuthMode = "token"
+		}
+	case "app", "token":
+	default:
+		log.Fatalf("invalid variable, AuthMode : %v", Configs.AuthMode)
+	}
+
+	if Configs.AuthMode == "token" && Configs.Token == "" && !Configs.Offline {
+		log.Fatalf("GITHUB_TOKEN is required with AUTH_MODE=token")
+	}
+
+	if Configs.AuthMode == "app" {
+		Configs.InstallationID = int64(getInt("INSTALLATION_ID", 0))
+		Configs.AllInstallations = getBool("ALL_INSTALLATIONS", false)
+	}
+
+	if len(Configs.AccountNames) == 0 && !Configs.AllInstallations {
+		log.Fatalf("ACCOUNT_NAME is required")
+	}
+
+	if len(Configs.AccountNames) > 1 && Configs.InstallationID != 0 {
+		log.Fatalf("INSTALLATION_ID can't be set with more than one ACCOUNT_NAME")
 	}
-	Configs.InstallationID = int64(insID)
 
 	baseStr := strings.TrimSpace(os.Getenv("BASE"))
 	if baseStr != "" {
@@ -62,4 +199,134 @@ func InitConfigs() {
 		// assign default value: 30 days.
 		Configs.Base = -30
 	}
+
+	Configs.Concurrency = getInt("CONCURRENCY", 8)
+	if Configs.Concurrency < 1 {
+		log.Fatalf("invalid variable, Concurrency : %v", Configs.Concurrency)
+	}
+
+	Configs.MaxRetries = getInt("MAX_RETRIES", 5)
+	Configs.MatrixApprovalsOnly = getBool("MATRIX_APPROVALS_ONLY", false)
+
+	Configs.Bucket = strings.ToLower(strings.TrimSpace(os.Getenv("BUCKET")))
+	switch Configs.Bucket {
+	case "", "day", "week", "month":
+	default:
+		log.Fatalf("invalid variable, Bucket : %v", Configs.Bucket)
+	}
+
+	Configs.BucketFormat = strings.ToLower(strings.TrimSpace(os.Getenv("BUCKET_FORMAT")))
+	switch Configs.BucketFormat {
+	case "":
+		Configs.BucketFormat = "long"
+	case "long", "wide":
+	default:
+		log.Fatalf("invalid variable, BucketFormat : %v", Configs.BucketFormat)
+	}
+
+	Configs.BaselineStartDate = strings.TrimSpace(os.Getenv("BASELINE_START"))
+	Configs.BaselineEndDate = strings.TrimSpace(os.Getenv("BASELINE_END"))
+	if (Configs.BaselineStartDate == "") != (Configs.BaselineEndDate == "") {
+		log.Fatalf("BASELINE_START and BASELINE_END must be set together")
+	}
+
+	// a baseline window implies the comparison
+	Configs.Compare = getBool("COMPARE", Configs.BaselineStartDate != "")
+
+	if thresholds := getList("SIZE_THRESHOLDS"); len(thresholds) > 0 {
+		if len(thresholds) != 4 {
+			log.Fatalf("invalid variable, SizeThresholds : %v", os.Getenv("SIZE_THRESHOLDS"))
+		}
+
+		for i, str := range thresholds {
+			val, err := strconv.Atoi(str)
+			if err != nil || val < 0 || (i > 0 && val <= Configs.SizeThresholds[i-1]) {
+				log.Fatalf("invalid variable, SizeThresholds : %v", os.Getenv("SIZE_THRESHOLDS"))
+			}
+
+			Configs.SizeThresholds = append(Configs.SizeThresholds, val)
+		}
+	}
+
+	Configs.ExcludeLogins = getList("EXCLUDE_LOGINS")
+	for _, pattern := range Configs.ExcludeLogins {
+		if _, err := path.Match(pattern, ""); err != nil {
+			log.Fatalf("invalid variable, ExcludeLogins : %v", pattern)
+		}
+	}
+
+	Configs.ExcludeBots = getBool("EXCLUDE_BOTS", false)
+
+	Configs.Repos = getList("REPOS")
+	Configs.RepoInclude = getList("REPO_INCLUDE")
+	Configs.RepoExclude = getList("REPO_EXCLUDE")
+	for _, pattern := range append(Configs.RepoInclude, Configs.RepoExclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			log.Fatalf("invalid variable, RepoInclude/RepoExclude : %v", pattern)
+		}
+	}
+
+	Configs.SkipArchived = getBool("SKIP_ARCHIVED", false)
+	Configs.SkipForks = getBool("SKIP_FORKS", false)
+	Configs.RepoTopics = getList("REPO_TOPICS")
+	Configs.RepoVisibilities = getList("REPO_VISIBILITY")
+	for _, visibility := range Configs.RepoVisibilities {
+		switch strings.ToLower(visibility) {
+		case "public", "private", "internal":
+		default:
+			log.Fatalf("invalid variable, RepoVisibilities : %v", visibility)
+		}
+	}
+
+	Configs.SkipComments = getBool("SKIP_COMMENTS", false)
+	Configs.Teams = getBool("TEAMS", false)
+	Configs.TeamAttribution = strings.ToLower(strings.TrimSpace(os.Getenv("TEAM_ATTRIBUTION")))
+	switch Configs.TeamAttribution {
+	case "":
+		Configs.TeamAttribution = "all"
+	case "all", "first":
+	default:
+		log.Fatalf("invalid variable, TeamAttribution : %v", Configs.TeamAttribution)
+	}
+}
+
+// getInt returns the env variable key as int or def when it isn't set
+func getInt(key string, def int) int {
+	str := strings.TrimSpace(os.Getenv(key))
+	if str == "" {
+		return def
+	}
+
+	val, err := strconv.Atoi(str)
+	if err != nil {
+		log.Fatalf("invalid variable, %v : %v", key, str)
+	}
+
+	return val
+}
+
+// getBool returns the env variable key as bool or def when it isn't set
+func getBool(key string, def bool) bool {
+	str := strings.TrimSpace(os.Getenv(key))
+	if str == "" {
+		return def
+	}
+
+	val, err := strconv.ParseBool(str)
+	if err != nil {
+		log.Fatalf("invalid variable, %v : %v", key, str)
+	}
+
+	return val
+}
+
+// getList returns the comma separated env variable key as a list
+func getList(key string) (list []string) {
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
 }
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/knishioka/github-pr-stats/models"
+)
+
+// Bucket sizes
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// getBuckets splits the window into calendar days, ISO weeks or months and
+// aggregates the PRs & reviews of each of them per user, the first and last
+// buckets are cut to the window
+func (e *Engine) getBuckets(prs []*models.PullRequest, users []*models.User) ([]*models.Bucket, error) {
+	var buckets []*models.Bucket
+	from, err := bucketStart(e.Bucket, e.Start)
+	if err != nil {
+		return nil, err
+	}
+
+	for from.Before(e.End) {
+		next := nextBucket(e.Bucket, from)
+		start, end := from, next
+		if start.Before(e.Start) {
+			start = e.Start
+		}
+
+		if e.End.Before(end) {
+			end = e.End
+		}
+
+		buckets = append(buckets, &models.Bucket{
+			Label: bucketLabel(e.Bucket, from),
+			Start: start,
+			End:   end,
+			Users: sortUsers(e.getStats(prs, users, start, end)),
+		})
+
+		from = next
+	}
+
+	return buckets, nil
+}
+
+// bucketStart returns the start of the bucket of the given size t falls in
+func bucketStart(size string, t time.Time) (time.Time, error) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch size {
+	case BucketDay:
+		return day, nil
+	case BucketWeek:
+		// ISO weeks start on monday
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7), nil
+	case BucketMonth:
+		return day.AddDate(0, 0, 1-day.Day()), nil
+	default:
+		return t, fmt.Errorf("unknown bucket size: %v", size)
+	}
+}
+
+// nextBucket returns the start of the bucket following the one starting at from
+func nextBucket(size string, from time.Time) time.Time {
+	switch size {
+	case BucketWeek:
+		return from.AddDate(0, 0, 7)
+	case BucketMonth:
+		return from.AddDate(0, 1, 0)
+	default:
+		return from.AddDate(0, 0, 1)
+	}
+}
+
+// bucketLabel names the bucket starting at from
+func bucketLabel(size string, from time.Time) string {
+	switch size {
+	case BucketWeek:
+		year, week := from.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case BucketMonth:
+		return from.Format("2006-01")
+	default:
+		return from.Format("2006-01-02")
+	}
+}
@@ -3,6 +3,8 @@ package engine
 import (
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/knishioka/github-pr-stats/exporter"
@@ -14,112 +16,445 @@ import (
 //Engine gets token from token agent, gets the required data
 //From github through git helper and exports using exporter
 type Engine struct {
-	Getter     gitutil.GitHelper
-	Exporter   exporter.ExportInterface
-	TokenAgent token.InsTokenInterface
-	Start      time.Time
-	End        time.Time
+	Getter   gitutil.GitHelper
+	Exporter exporter.ExportInterface
+	//Targets has a token agent for each org to collect the stats of
+	Targets []token.InsTokenInterface
+	Start   time.Time
+	End     time.Time
 	//Base defines #days before the startDate
 	//Before which the system should ignore All the PRs
 	Base int
+	//MatrixApprovalsOnly counts only the approvals in the reviewer to author matrix
+	MatrixApprovalsOnly bool
+	//Bucket splits the window into day, week or month buckets with
+	//their own per user stats, no bucketing if empty
+	Bucket string
+	//BucketFormat is the layout of the bucketed stats in the exports: long or wide
+	BucketFormat string
+	//Compare computes the per user stats of a baseline window as well,
+	//the previous window of the same length unless BaselineStart &
+	//BaselineEnd are set
+	Compare       bool
+	BaselineStart time.Time
+	BaselineEnd   time.Time
+	//SizeThresholds are the max lines changed of the XS, S, M & L
+	//PR size classes, DefaultSizeThresholds if not set
+	SizeThresholds []int
+	//ExcludeLogins are logins or login patterns, as of path.Match, of the users
+	//to leave out of the stats along with their PRs, reviews & comments.
+	//The REST API gives the Github App logins a [bot] suffix, the GraphQL
+	//API doesn't: dependabot[bot] with rest is dependabot with graphql
+	//while dependabot* matches both
+	ExcludeLogins []string
+	//ExcludeBots leaves out the users of the Bot account type
+	ExcludeBots bool
+	//Teams gets the teams of each org & rolls the stats up per team
+	//TeamAttribution is either TeamAttributionAll or TeamAttributionFirst
+	Teams           bool
+	TeamAttribution string
+	//Offline gets everything from a store through the Getter,
+	//no installation token is generated
+	Offline bool
 }
 
 //Run starts the engine
 func (e *Engine) Run() error {
 	base := e.Start.AddDate(0, 0, e.Base)
+	baselineStart, baselineEnd := e.baseline()
+	if e.Compare && baselineStart.Before(e.Start) {
+		// the PRs of the baseline window have to be fetched too
+		base = baselineStart.AddDate(0, 0, e.Base)
+	}
+
 	e.Getter.SetBase(base)
-	err := e.TokenAgent.GenerateNew()
-	if err != nil {
-		log.Fatalf("error getting installation token: %v", err.Error())
+
+	report := &models.Report{
+		Start:    e.Start,
+		End:      e.End,
+		OrgUsers: make(map[string][]*models.User),
+	}
+
+	var allPrs []*models.PullRequest
+	var allUsers []*models.User
+	var allTeams []*models.Team
+	for _, target := range e.Targets {
+		users, prs, teams := e.collect(target)
+		prs, users = e.exclude(prs, users, &report.Excluded)
+
+		log.Printf("generating %v stats", target.AccountName())
+		report.Orgs = append(report.Orgs, target.AccountName())
+		report.OrgUsers[target.AccountName()] = sortUsers(e.getStats(prs, users, e.Start, e.End))
+
+		allPrs = append(allPrs, prs...)
+		allUsers = append(allUsers, users...)
+		allTeams = append(allTeams, teams...)
+	}
+
+	if !report.Excluded.Empty() {
+		log.Printf("excluded %v PRs, %v reviews, %v comments & %v members of %v",
+			report.Excluded.PullReqs, report.Excluded.Reviews, report.Excluded.Comments,
+			report.Excluded.Members, strings.Join(report.Excluded.Logins, ", "))
+	}
+
+	log.Println("generating stats")
+	report.Users = sortUsers(e.getStats(allPrs, allUsers, e.Start, e.End))
+	if e.Compare {
+		report.BaselineStart = baselineStart
+		report.BaselineEnd = baselineEnd
+		report.BaselineUsers = sortUsers(e.getStats(allPrs, allUsers, baselineStart, baselineEnd))
+	}
+	report.Repos = e.getRepoStats(allPrs)
+	report.PullRequests = e.activePullRequests(allPrs)
+	report.Latency = e.getLatencyStats(allPrs)
+	report.Sizes = e.getSizeStats(allPrs)
+	if e.Teams {
+		report.Teams = e.getTeamStats(allTeams, report, allPrs)
+	}
+	report.Interactions = e.getInteractions(allPrs)
+	report.ApprovalsOnly = e.MatrixApprovalsOnly
+	if e.Bucket != "" {
+		buckets, err := e.getBuckets(allPrs, allUsers)
+		if err != nil {
+			log.Fatalf("error bucketing stats: %v", err.Error())
+		}
+
+		report.Bucket = e.Bucket
+		report.BucketFormat = e.BucketFormat
+		report.Buckets = buckets
+	}
+	report.GeneratedAt = time.Now()
+
+	log.Println("exporting stats")
+	dateformat := "2006-01-02"
+	filename := fmt.Sprintf("results_%v_%v_to_%v", strings.Join(report.Orgs, "_"), e.Start.Format(dateformat), e.End.Format(dateformat))
+	if err := e.Exporter.Export(report, filename); err != nil {
+		log.Fatalf("error exporting results: %v", err.Error())
+	}
+
+	log.Printf("stats exported to %v", filename)
+
+	return nil
+}
+
+//collect gets the members, the pull requests & with Teams the teams
+//of the org of the token agent
+func (e *Engine) collect(target token.InsTokenInterface) ([]*models.User, []*models.PullRequest, []*models.Team) {
+	if !e.Offline {
+		err := target.GenerateNew()
+		if err != nil {
+			log.Fatalf("error getting installation token: %v", err.Error())
+		}
 	}
 
-	log.Println("gettingr org members")
-	users, err := e.Getter.GetOrgMembers(e.TokenAgent)
+	log.Printf("gettingr %v members", target.AccountName())
+	users, err := e.Getter.GetOrgMembers(target)
 	if err != nil {
 		log.Fatalf("error getting org members: %v", err.Error())
 	}
 
-	log.Println("gettingr org repos")
-	repos, err := e.Getter.GetOrgRepos(e.TokenAgent)
+	log.Printf("gettingr %v repos", target.AccountName())
+	repos, err := e.Getter.GetOrgRepos(target)
 	if err != nil {
 		log.Fatalf("error getting org repos: %v", err.Error())
 	}
 
 	log.Printf("repos found: %v\n", len(repos))
 	log.Println("getting pull requests")
-	prs, err := e.Getter.GetPullRequests(repos, e.TokenAgent)
+	prs, err := e.Getter.GetPullRequests(repos, target)
 	if err != nil {
 		log.Fatalf("error getting repos pull requests: %v", err.Error())
 	}
 
-	log.Println("generating stats")
-	stats := e.getStats(prs, users)
+	if !e.Teams {
+		return users, prs, nil
+	}
 
-	log.Println("exporting stats")
-	dateformat := "2006-01-02"
-	filename := fmt.Sprintf("results_%v_to_%v.csv", e.Start.Format(dateformat), e.End.Format(dateformat))
-	if err := e.Exporter.Export(stats, filename); err != nil {
-		log.Fatalf("error exporting results: %v", err.Error())
+	log.Printf("getting %v teams", target.AccountName())
+	teams, err := e.Getter.GetOrgTeams(target)
+	if err != nil {
+		log.Fatalf("error getting org teams: %v", err.Error())
 	}
 
-	log.Printf("stats exported to %v", filename)
+	return users, prs, teams
+}
 
-	return nil
+//baseline returns the window the stats are compared to
+func (e *Engine) baseline() (time.Time, time.Time) {
+	if !e.BaselineStart.IsZero() && !e.BaselineEnd.IsZero() {
+		return e.BaselineStart, e.BaselineEnd
+	}
+
+	return e.Start.Add(-e.End.Sub(e.Start)), e.Start
+}
+
+//inWindow tells whether t is after Start and up to End
+func (e *Engine) inWindow(t time.Time) bool {
+	return within(e.Start, e.End, t)
+}
+
+//within tells whether t is after start and up to end
+func within(start, end, t time.Time) bool {
+	return start.Before(t) && !end.Before(t)
+}
+
+//reviewWithin tells whether review of pr counts from start, excluded, to end:
+//the author answering the reviews isn't reviewing
+func reviewWithin(pr *models.PullRequest, review *models.Review, start, end time.Time) bool {
+	return within(start, end, review.SubmittedAt) && review.UserID != pr.UserID
+}
+
+//activePullRequests returns the PRs created, reviewed or commented within the window
+func (e *Engine) activePullRequests(prs []*models.PullRequest) (active []*models.PullRequest) {
+	for i := 0; i < len(prs); i++ {
+		inWindow := e.inWindow(prs[i].CreatedAt)
+		for j := 0; j < len(prs[i].Reviews) && !inWindow; j++ {
+			inWindow = e.inWindow(prs[i].Reviews[j].SubmittedAt)
+		}
+
+		for j := 0; j < len(prs[i].Comments) && !inWindow; j++ {
+			inWindow = e.inWindow(prs[i].Comments[j].CreatedAt)
+		}
+
+		if inWindow {
+			active = append(active, prs[i])
+		}
+	}
+
+	return active
+}
+
+//getRepoStats aggregates the PRs & reviews of the window per repo
+func (e *Engine) getRepoStats(prs []*models.PullRequest) []*models.RepoStats {
+	stats := make(map[string]*models.RepoStats)
+	authors := make(map[string]map[int64]bool)
+	reviewers := make(map[string]map[int64]bool)
+	lines := make(map[string][]int)
+	var repos []*models.RepoStats
+	for i := 0; i < len(prs); i++ {
+		key := prs[i].Org + "/" + prs[i].RepoName
+		if stats[key] == nil {
+			stats[key] = &models.RepoStats{Org: prs[i].Org, Name: prs[i].RepoName}
+			authors[key] = make(map[int64]bool)
+			reviewers[key] = make(map[int64]bool)
+			repos = append(repos, stats[key])
+		}
+
+		for j := 0; j < len(prs[i].Reviews); j++ {
+			if reviewWithin(prs[i], prs[i].Reviews[j], e.Start, e.End) {
+				stats[key].Reviews++
+				reviewers[key][prs[i].Reviews[j].UserID] = true
+			}
+		}
+
+		if !e.inWindow(prs[i].CreatedAt) {
+			continue
+		}
+
+		stats[key].PullReqsOpened++
+		stats[key].TotalAdditions += prs[i].Additions
+		stats[key].TotalDeletions += prs[i].Deletions
+		stats[key].TotalChangedFiles += prs[i].ChangedFiles
+		stats[key].TotalCommits += prs[i].Commits
+		stats[key].SizeCounts.Add(e.sizeClass(prs[i]))
+		authors[key][prs[i].UserID] = true
+		lines[key] = append(lines[key], prs[i].Lines())
+	}
+
+	for key := range stats {
+		stats[key].Authors = len(authors[key])
+		stats[key].Reviewers = len(reviewers[key])
+		stats[key].MedianPullReqSize = median(lines[key])
+	}
+
+	sort.Slice(repos, func(i, j int) bool {
+		if repos[i].Org != repos[j].Org {
+			return repos[i].Org < repos[j].Org
+		}
+
+		return repos[i].Name < repos[j].Name
+	})
+
+	return repos
+}
+
+//sortUsers returns the users of stats ordered by username
+func sortUsers(stats map[int64]*models.User) []*models.User {
+	users := make([]*models.User, 0, len(stats))
+	for _, user := range stats {
+		users = append(users, user)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Username != users[j].Username {
+			return users[i].Username < users[j].Username
+		}
+
+		return users[i].ID < users[j].ID
+	})
+
+	return users
 }
 
-func (e *Engine) getStats(prs []*models.PullRequest, users []*models.User) map[string]*models.User {
-	stats := make(map[string]*models.User)
+//getStats aggregates the PRs & reviews from start, excluded, to end per user ID
+func (e *Engine) getStats(prs []*models.PullRequest, users []*models.User, start, end time.Time) map[int64]*models.User {
+	stats := make(map[int64]*models.User)
+	// comments written by each user on the PRs they reviewed
+	onReviewed := make(map[int64]int)
+	// lines changed by each PR the user created
+	lines := make(map[int64][]int)
 	for i := 0; i < len(prs); i++ {
+		reviewed := make(map[int64]bool)
 		for j := 0; j < len(prs[i].Reviews); j++ {
-			if !e.Start.Before(prs[i].Reviews[j].SubmittedAt) {
+			if !reviewWithin(prs[i], prs[i].Reviews[j], start, end) {
+				continue
+			}
+
+			if stats[prs[i].Reviews[j].UserID] == nil {
+				stats[prs[i].Reviews[j].UserID] = &models.User{}
+			}
+
+			stats[prs[i].Reviews[j].UserID].Username = prs[i].Reviews[j].Username
+			stats[prs[i].Reviews[j].UserID].ID = prs[i].Reviews[j].UserID
+			stats[prs[i].Reviews[j].UserID].ReviewSubmissions++
+			if !reviewed[prs[i].Reviews[j].UserID] {
+				reviewed[prs[i].Reviews[j].UserID] = true
+				stats[prs[i].Reviews[j].UserID].PullReqsReviewed++
+			}
+
+			switch prs[i].Reviews[j].State {
+			case models.ReviewApproved:
+				stats[prs[i].Reviews[j].UserID].ReviewsApproved++
+			case models.ReviewChangesRequested:
+				stats[prs[i].Reviews[j].UserID].ReviewsChangesRequested++
+			case models.ReviewCommented:
+				stats[prs[i].Reviews[j].UserID].ReviewsCommented++
+			case models.ReviewDismissed:
+				stats[prs[i].Reviews[j].UserID].ReviewsDismissed++
+			}
+		}
+
+		for j := 0; j < len(prs[i].Comments); j++ {
+			if !within(start, end, prs[i].Comments[j].CreatedAt) {
 				continue
 			}
 
-			if e.End.Before(prs[i].Reviews[j].SubmittedAt) {
+			if prs[i].Comments[j].UserID == prs[i].UserID {
 				continue
 			}
 
-			if stats[prs[i].Reviews[j].Username] == nil {
-				stats[prs[i].Reviews[j].Username] = &models.User{}
+			if stats[prs[i].Comments[j].UserID] == nil {
+				stats[prs[i].Comments[j].UserID] = &models.User{}
+			}
+
+			stats[prs[i].Comments[j].UserID].Username = prs[i].Comments[j].Username
+			stats[prs[i].Comments[j].UserID].ID = prs[i].Comments[j].UserID
+			stats[prs[i].Comments[j].UserID].CommentsWritten++
+			if reviewed[prs[i].Comments[j].UserID] {
+				onReviewed[prs[i].Comments[j].UserID]++
+			}
+
+			if stats[prs[i].UserID] == nil {
+				stats[prs[i].UserID] = &models.User{}
 			}
 
-			stats[prs[i].Reviews[j].Username].Username = prs[i].Reviews[j].Username
-			stats[prs[i].Reviews[j].Username].ID = prs[i].Reviews[j].ID
-			stats[prs[i].Reviews[j].Username].PullReqsReviewed++
+			stats[prs[i].UserID].Username = prs[i].Username
+			stats[prs[i].UserID].ID = prs[i].UserID
+			stats[prs[i].UserID].CommentsReceived++
 		}
 
-		if !e.Start.Before(prs[i].CreatedAt) {
+		if prs[i].Merged && prs[i].MergedByID != 0 && within(start, end, prs[i].MergedAt) &&
+			!e.excluded(prs[i].MergedBy, prs[i].MergedByType) {
+			if stats[prs[i].MergedByID] == nil {
+				stats[prs[i].MergedByID] = &models.User{}
+			}
+
+			stats[prs[i].MergedByID].Username = prs[i].MergedBy
+			stats[prs[i].MergedByID].ID = prs[i].MergedByID
+			stats[prs[i].MergedByID].PullReqsMergedBy++
+		}
+
+		if !start.Before(prs[i].CreatedAt) {
 			continue
 		}
 
-		if e.End.Before(prs[i].CreatedAt) {
+		if end.Before(prs[i].CreatedAt) {
 			continue
 		}
 
-		if stats[prs[i].Username] == nil {
-			stats[prs[i].Username] = &models.User{}
+		if stats[prs[i].UserID] == nil {
+			stats[prs[i].UserID] = &models.User{}
+		}
+
+		stats[prs[i].UserID].PullReqsCreated++
+		stats[prs[i].UserID].TotalAdditions += prs[i].Additions
+		stats[prs[i].UserID].TotalDeletions += prs[i].Deletions
+		stats[prs[i].UserID].TotalChangedFiles += prs[i].ChangedFiles
+		stats[prs[i].UserID].TotalCommits += prs[i].Commits
+		for j := 0; j < len(prs[i].Reviews); j++ {
+			if reviewWithin(prs[i], prs[i].Reviews[j], start, end) {
+				stats[prs[i].UserID].ReviewsOnPullReqs++
+			}
+		}
+		stats[prs[i].UserID].SizeCounts.Add(e.sizeClass(prs[i]))
+		lines[prs[i].UserID] = append(lines[prs[i].UserID], prs[i].Lines())
+		if hasReview(prs[i], models.ReviewApproved) {
+			stats[prs[i].UserID].PullReqsApproved++
+		}
+
+		if hasReview(prs[i], models.ReviewChangesRequested) {
+			stats[prs[i].UserID].PullReqsChangesRequested++
 		}
 
-		stats[prs[i].Username].PullReqsCreated++
-		stats[prs[i].Username].TotalAdditions += prs[i].Additions
-		stats[prs[i].Username].TotalDeletions += prs[i].Deletions
-		stats[prs[i].Username].TotalChangedFiles += prs[i].ChangedFiles
-		stats[prs[i].Username].TotalCommits += prs[i].Commits
-		stats[prs[i].Username].ReviewsOnPullReqs += len(prs[i].Reviews)
-		stats[prs[i].Username].Username = prs[i].Username
-		stats[prs[i].Username].ID = prs[i].UserID
+		switch {
+		case prs[i].Merged:
+			stats[prs[i].UserID].PullReqsMerged++
+		case prs[i].State == "closed":
+			stats[prs[i].UserID].PullReqsClosedUnmerged++
+		default:
+			stats[prs[i].UserID].PullReqsOpen++
+		}
+		stats[prs[i].UserID].Username = prs[i].Username
+		stats[prs[i].UserID].ID = prs[i].UserID
 	}
 
 	// Add the users which didn't create any PR
 	// Nor did they gave any review
 	for j := 0; j < len(users); j++ {
-		if stats[users[j].Username] == nil {
-			stats[users[j].Username] = &models.User{
+		if stats[users[j].ID] == nil {
+			stats[users[j].ID] = &models.User{
 				ID:       users[j].ID,
 				Username: users[j].Username,
 			}
 		}
 	}
 
+	for _, user := range stats {
+		user.ReviewApprovalRate = rate(user.ReviewsApproved, user.ReviewSubmissions)
+		user.PullReqApprovalRate = rate(user.PullReqsApproved, user.PullReqsCreated)
+		user.CommentsPerReviewedPullReq = rate(onReviewed[user.ID], user.PullReqsReviewed)
+		user.MedianPullReqSize = median(lines[user.ID])
+	}
+
 	return stats
 }
+
+//hasReview tells whether pr got at least one review in the given state
+func hasReview(pr *models.PullRequest, state string) bool {
+	for _, review := range pr.Reviews {
+		if review.State == state {
+			return true
+		}
+	}
+
+	return false
+}
+
+//rate returns n/total, 0 when total is 0
+func rate(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(n) / float64(total)
+}
@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/knishioka/github-pr-stats/models"
+)
+
+func TestSelfReviewsLeftOut(t *testing.T) {
+	end := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	start := end.AddDate(0, 0, -7)
+	e := &Engine{Start: start, End: end}
+	review := func(userID int64, at time.Time) *models.Review {
+		return &models.Review{UserID: userID, State: models.ReviewCommented, SubmittedAt: at}
+	}
+
+	prs := []*models.PullRequest{{
+		Org: "org", RepoName: "repo", PrNo: 1, UserID: 1, Username: "author",
+		CreatedAt: start.Add(time.Hour),
+		Reviews: []*models.Review{
+			review(1, start.Add(2*time.Hour)),
+			review(2, start.Add(3*time.Hour)),
+			review(1, start.Add(4*time.Hour)),
+			// after the window
+			review(2, end.Add(time.Hour)),
+		},
+	}}
+
+	stats := e.getStats(prs, nil, start, end)
+	repos := e.getRepoStats(prs)
+	if len(repos) != 1 {
+		t.Fatalf("getRepoStats() = %v repos, want 1", len(repos))
+	}
+
+	if got := stats[1].ReviewsOnPullReqs; got != 1 || got != repos[0].Reviews {
+		t.Errorf("author ReviewsOnPullReqs = %v, repo Reviews = %v, want 1", got, repos[0].Reviews)
+	}
+
+	if stats[1].ReviewSubmissions != 0 || stats[2].ReviewSubmissions != 1 {
+		t.Errorf("ReviewSubmissions = %v & %v, want 0 & 1", stats[1].ReviewSubmissions, stats[2].ReviewSubmissions)
+	}
+
+	if repos[0].Reviewers != 1 {
+		t.Errorf("repo Reviewers = %v, want 1", repos[0].Reviewers)
+	}
+}
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/knishioka/github-pr-stats/models"
+)
+
+// excluded tells whether the user of the given login & account type is left
+// out of the stats, logins are matched case insensitively. A login equal to
+// a pattern matches before globbing, as path.Match reads the [bot] suffix
+// of the Github App logins as a character class.
+func (e *Engine) excluded(login, userType string) bool {
+	if e.ExcludeBots && userType == models.UserTypeBot {
+		return true
+	}
+
+	for _, pattern := range e.ExcludeLogins {
+		if strings.EqualFold(pattern, login) {
+			return true
+		}
+
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(login)); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// exclude returns the PRs & the members without the excluded users: the PRs
+// they authored, their reviews & their comments are left out and counted in
+// excl. The PRs are copied rather than changed.
+func (e *Engine) exclude(prs []*models.PullRequest, users []*models.User, excl *models.Exclusions) ([]*models.PullRequest, []*models.User) {
+	if !e.ExcludeBots && len(e.ExcludeLogins) == 0 {
+		return prs, users
+	}
+
+	logins := make(map[string]bool)
+	for _, login := range excl.Logins {
+		logins[login] = true
+	}
+
+	var keptPrs []*models.PullRequest
+	for i := 0; i < len(prs); i++ {
+		if e.excluded(prs[i].Username, prs[i].UserType) {
+			excl.PullReqs++
+			logins[prs[i].Username] = true
+			continue
+		}
+
+		pr := *prs[i]
+		pr.Reviews = []*models.Review{}
+		for _, review := range prs[i].Reviews {
+			if e.excluded(review.Username, review.UserType) {
+				excl.Reviews++
+				logins[review.Username] = true
+				continue
+			}
+
+			pr.Reviews = append(pr.Reviews, review)
+		}
+
+		pr.Comments = []*models.Comment{}
+		for _, comment := range prs[i].Comments {
+			if e.excluded(comment.Username, comment.UserType) {
+				excl.Comments++
+				logins[comment.Username] = true
+				continue
+			}
+
+			pr.Comments = append(pr.Comments, comment)
+		}
+
+		keptPrs = append(keptPrs, &pr)
+	}
+
+	var keptUsers []*models.User
+	for j := 0; j < len(users); j++ {
+		// the members API gives no account type, only the logins apply
+		if e.excluded(users[j].Username, "") {
+			excl.Members++
+			continue
+		}
+
+		keptUsers = append(keptUsers, users[j])
+	}
+
+	excl.Logins = excl.Logins[:0]
+	for login := range logins {
+		excl.Logins = append(excl.Logins, login)
+	}
+	sort.Strings(excl.Logins)
+
+	return keptPrs, keptUsers
+}
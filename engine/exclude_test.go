@@ -0,0 +1,39 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/knishioka/github-pr-stats/models"
+)
+
+func TestExcluded(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		bots     bool
+		login    string
+		userType string
+		want     bool
+	}{
+		{name: "no rules", login: "alice"},
+		{name: "exact", patterns: []string{"ci-bot"}, login: "ci-bot", want: true},
+		{name: "case insensitive", patterns: []string{"CI-Bot"}, login: "ci-bot", want: true},
+		{name: "app login with brackets", patterns: []string{"dependabot[bot]"}, login: "dependabot[bot]", want: true},
+		{name: "app login case insensitive", patterns: []string{"Renovate[bot]"}, login: "renovate[bot]", want: true},
+		{name: "glob", patterns: []string{"renovate*"}, login: "renovate[bot]", want: true},
+		{name: "glob graphql login", patterns: []string{"dependabot*"}, login: "dependabot", want: true},
+		{name: "brackets don't match the graphql login", patterns: []string{"dependabot[bot]"}, login: "dependabot"},
+		{name: "no match", patterns: []string{"renovate*", "ci-bot"}, login: "alice"},
+		{name: "bot type", bots: true, login: "dependabot", userType: models.UserTypeBot, want: true},
+		{name: "bot type not excluded", login: "dependabot", userType: models.UserTypeBot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Engine{ExcludeLogins: tt.patterns, ExcludeBots: tt.bots}
+			if got := e.excluded(tt.login, tt.userType); got != tt.want {
+				t.Errorf("excluded(%q, %q) = %v, want %v", tt.login, tt.userType, got, tt.want)
+			}
+		})
+	}
+}
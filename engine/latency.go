@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/knishioka/github-pr-stats/models"
+)
+
+// Latency scopes
+const (
+	scopeAuthor   = "author"
+	scopeReviewer = "reviewer"
+	scopeRepo     = "repo"
+)
+
+// latencySamples holds the durations measured for an author, reviewer or repo
+type latencySamples struct {
+	scope, name                  string
+	firstReview, approval, merge []time.Duration
+}
+
+// getLatencyStats measures the time to first review, approval & merge of the
+// PRs created within the window, per author, per reviewer & per repo
+func (e *Engine) getLatencyStats(prs []*models.PullRequest) []*models.LatencyStats {
+	samples := make(map[string]*latencySamples)
+	get := func(scope, name string) *latencySamples {
+		key := scope + "\x00" + name
+		if samples[key] == nil {
+			samples[key] = &latencySamples{scope: scope, name: name}
+		}
+
+		return samples[key]
+	}
+
+	for i := 0; i < len(prs); i++ {
+		pr := prs[i]
+		if !e.inWindow(pr.CreatedAt) {
+			continue
+		}
+
+		scopes := []*latencySamples{get(scopeAuthor, pr.Username), get(scopeRepo, pr.Org+"/"+pr.RepoName)}
+		for _, s := range scopes {
+			if at := pr.FirstReviewAt(); !at.IsZero() {
+				s.firstReview = append(s.firstReview, at.Sub(pr.CreatedAt))
+			}
+
+			if at := pr.ApprovedAt(); !at.IsZero() {
+				s.approval = append(s.approval, at.Sub(pr.CreatedAt))
+			}
+
+			if !pr.MergedAt.IsZero() {
+				s.merge = append(s.merge, pr.MergedAt.Sub(pr.CreatedAt))
+			}
+		}
+
+		// each reviewer's first review & approval of the PR
+		firstReview := make(map[string]time.Time)
+		approval := make(map[string]time.Time)
+		for _, review := range pr.Reviews {
+			if review.UserID == pr.UserID || review.SubmittedAt.IsZero() {
+				continue
+			}
+
+			if at, ok := firstReview[review.Username]; !ok || review.SubmittedAt.Before(at) {
+				firstReview[review.Username] = review.SubmittedAt
+			}
+
+			if at, ok := approval[review.Username]; review.State == models.ReviewApproved && (!ok || review.SubmittedAt.Before(at)) {
+				approval[review.Username] = review.SubmittedAt
+			}
+		}
+
+		for username, at := range firstReview {
+			s := get(scopeReviewer, username)
+			s.firstReview = append(s.firstReview, at.Sub(pr.CreatedAt))
+		}
+
+		for username, at := range approval {
+			s := get(scopeReviewer, username)
+			s.approval = append(s.approval, at.Sub(pr.CreatedAt))
+		}
+	}
+
+	stats := make([]*models.LatencyStats, 0, len(samples))
+	for _, s := range samples {
+		stats = append(stats, &models.LatencyStats{
+			Scope:             s.scope,
+			Name:              s.name,
+			TimeToFirstReview: summarize(s.firstReview),
+			TimeToApproval:    summarize(s.approval),
+			TimeToMerge:       summarize(s.merge),
+		})
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Scope != stats[j].Scope {
+			return stats[i].Scope < stats[j].Scope
+		}
+
+		return stats[i].Name < stats[j].Name
+	})
+
+	return stats
+}
+
+// summarize returns the median, p75, p90 & max of durations in hours
+func summarize(durations []time.Duration) models.Latency {
+	if len(durations) == 0 {
+		return models.Latency{}
+	}
+
+	hours := make([]float64, len(durations))
+	for i, d := range durations {
+		hours[i] = d.Hours()
+	}
+	sort.Float64s(hours)
+
+	return models.Latency{
+		Count:  len(hours),
+		Median: percentile(hours, 50),
+		P75:    percentile(hours, 75),
+		P90:    percentile(hours, 90),
+		Max:    hours[len(hours)-1],
+	}
+}
+
+// percentile returns the p-th percentile of the sorted values,
+// interpolating linearly between the closest ranks
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"sort"
+
+	"github.com/knishioka/github-pr-stats/models"
+)
+
+// getInteractions counts the reviews each reviewer submitted within the window
+// on the PRs of each author, or only the approvals with MatrixApprovalsOnly.
+// Self reviews are left out.
+func (e *Engine) getInteractions(prs []*models.PullRequest) []*models.Interaction {
+	counts := make(map[[2]int64]*models.Interaction)
+	var interactions []*models.Interaction
+	for i := 0; i < len(prs); i++ {
+		for _, review := range prs[i].Reviews {
+			if !reviewWithin(prs[i], review, e.Start, e.End) {
+				continue
+			}
+
+			if e.MatrixApprovalsOnly && review.State != models.ReviewApproved {
+				continue
+			}
+
+			key := [2]int64{review.UserID, prs[i].UserID}
+			if counts[key] == nil {
+				counts[key] = &models.Interaction{
+					ReviewerID: review.UserID,
+					Reviewer:   review.Username,
+					AuthorID:   prs[i].UserID,
+					Author:     prs[i].Username,
+				}
+				interactions = append(interactions, counts[key])
+			}
+
+			counts[key].Reviews++
+		}
+	}
+
+	sort.Slice(interactions, func(i, j int) bool {
+		if interactions[i].Reviewer != interactions[j].Reviewer {
+			return interactions[i].Reviewer < interactions[j].Reviewer
+		}
+
+		return interactions[i].Author < interactions[j].Author
+	})
+
+	return interactions
+}
@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"sort"
+	"time"
+
+	"github.com/knishioka/github-pr-stats/models"
+)
+
+// DefaultSizeThresholds are the max lines changed of the XS, S, M & L size
+// classes, larger PRs are XL
+var DefaultSizeThresholds = []int{10, 100, 500, 1000}
+
+// thresholds returns the max lines changed of each size class but the largest
+func (e *Engine) thresholds() []int {
+	if len(e.SizeThresholds) == len(models.SizeClasses)-1 {
+		return e.SizeThresholds
+	}
+
+	return DefaultSizeThresholds
+}
+
+// sizeClass returns the index in models.SizeClasses of the size class of pr
+func (e *Engine) sizeClass(pr *models.PullRequest) int {
+	thresholds := e.thresholds()
+	for i, max := range thresholds {
+		if pr.Lines() <= max {
+			return i
+		}
+	}
+
+	return len(thresholds)
+}
+
+// getSizeStats measures how the PRs created within the window got reviewed
+// per size class: how many got reviewed, by how many reviews & reviewers
+// and how fast they got their first review & got merged
+func (e *Engine) getSizeStats(prs []*models.PullRequest) []*models.SizeStats {
+	thresholds := e.thresholds()
+	stats := make([]*models.SizeStats, len(models.SizeClasses))
+	reviews := make([]int, len(stats))
+	reviewers := make([]int, len(stats))
+	firstReview := make([][]time.Duration, len(stats))
+	merge := make([][]time.Duration, len(stats))
+	for i, class := range models.SizeClasses {
+		stats[i] = &models.SizeStats{Class: class}
+		if i > 0 {
+			stats[i].MinLines = thresholds[i-1] + 1
+		}
+
+		if i < len(thresholds) {
+			stats[i].MaxLines = thresholds[i]
+		}
+	}
+
+	for i := 0; i < len(prs); i++ {
+		if !e.inWindow(prs[i].CreatedAt) {
+			continue
+		}
+
+		class := e.sizeClass(prs[i])
+		stats[class].PullReqs++
+
+		reviewed := make(map[int64]bool)
+		for _, review := range prs[i].Reviews {
+			if review.UserID == prs[i].UserID {
+				continue
+			}
+
+			reviews[class]++
+			reviewed[review.UserID] = true
+		}
+
+		reviewers[class] += len(reviewed)
+		if len(reviewed) > 0 {
+			stats[class].Reviewed++
+		}
+
+		if at := prs[i].FirstReviewAt(); !at.IsZero() {
+			firstReview[class] = append(firstReview[class], at.Sub(prs[i].CreatedAt))
+		}
+
+		if !prs[i].MergedAt.IsZero() {
+			merge[class] = append(merge[class], prs[i].MergedAt.Sub(prs[i].CreatedAt))
+		}
+	}
+
+	for i := range stats {
+		stats[i].ReviewsPerPullReq = rate(reviews[i], stats[i].PullReqs)
+		stats[i].ReviewersPerPullReq = rate(reviewers[i], stats[i].PullReqs)
+		stats[i].TimeToFirstReview = summarize(firstReview[i])
+		stats[i].TimeToMerge = summarize(merge[i])
+	}
+
+	return stats
+}
+
+// median returns the median of values, 0 if there is none
+func median(values []int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := make([]float64, len(values))
+	for i, v := range values {
+		sorted[i] = float64(v)
+	}
+	sort.Float64s(sorted)
+
+	return percentile(sorted, 50)
+}
@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"sort"
+
+	"github.com/knishioka/github-pr-stats/models"
+)
+
+// Team attribution policies of the users in several teams
+const (
+	TeamAttributionAll   = "all"
+	TeamAttributionFirst = "first"
+)
+
+// getTeamStats attaches the team slugs to the users of the report and rolls
+// their stats up per team. A user in several teams is counted in each of them
+// with TeamAttributionAll, in the first of them by org & slug with
+// TeamAttributionFirst. With more than one org the slugs are prefixed by the org.
+func (e *Engine) getTeamStats(teams []*models.Team, report *models.Report, prs []*models.PullRequest) []*models.TeamStats {
+	sort.Slice(teams, func(i, j int) bool {
+		if teams[i].Org != teams[j].Org {
+			return teams[i].Org < teams[j].Org
+		}
+
+		return teams[i].Slug < teams[j].Slug
+	})
+
+	label := func(team *models.Team) string {
+		if len(report.Orgs) > 1 {
+			return team.Org + "/" + team.Slug
+		}
+
+		return team.Slug
+	}
+
+	stats := make([]*models.TeamStats, len(teams))
+	memberOf := make(map[int64][]int)
+	for i, team := range teams {
+		stats[i] = &models.TeamStats{
+			Org:   team.Org,
+			Slug:  team.Slug,
+			Name:  team.Name,
+			Stats: &models.User{ID: team.ID, Username: label(team)},
+		}
+
+		for _, member := range team.Members {
+			memberOf[member.ID] = append(memberOf[member.ID], i)
+		}
+	}
+
+	for org, users := range report.OrgUsers {
+		for _, user := range users {
+			for _, i := range memberOf[user.ID] {
+				if teams[i].Org == org {
+					user.Teams = append(user.Teams, teams[i].Slug)
+				}
+			}
+		}
+	}
+
+	// the teams each user's stats are rolled up into
+	attributed := make(map[int64][]int)
+	onReviewed := make([]float64, len(stats))
+	for _, user := range report.Users {
+		for _, i := range memberOf[user.ID] {
+			user.Teams = append(user.Teams, label(teams[i]))
+		}
+
+		attributed[user.ID] = memberOf[user.ID]
+		if e.TeamAttribution == TeamAttributionFirst && len(memberOf[user.ID]) > 1 {
+			attributed[user.ID] = memberOf[user.ID][:1]
+		}
+
+		for _, i := range attributed[user.ID] {
+			stats[i].Members++
+			stats[i].Stats.Add(user)
+			onReviewed[i] += user.CommentsPerReviewedPullReq * float64(user.PullReqsReviewed)
+		}
+	}
+
+	lines := make([][]int, len(stats))
+	for i := 0; i < len(prs); i++ {
+		if !e.inWindow(prs[i].CreatedAt) {
+			continue
+		}
+
+		for _, j := range attributed[prs[i].UserID] {
+			lines[j] = append(lines[j], prs[i].Lines())
+		}
+	}
+
+	for i, team := range stats {
+		team.Stats.ReviewApprovalRate = rate(team.Stats.ReviewsApproved, team.Stats.ReviewSubmissions)
+		team.Stats.PullReqApprovalRate = rate(team.Stats.PullReqsApproved, team.Stats.PullReqsCreated)
+		if team.Stats.PullReqsReviewed > 0 {
+			team.Stats.CommentsPerReviewedPullReq = onReviewed[i] / float64(team.Stats.PullReqsReviewed)
+		}
+
+		team.Stats.MedianPullReqSize = median(lines[i])
+	}
+
+	return stats
+}
@@ -0,0 +1,120 @@
+package exporter
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"encoding/csv"
+
+	"github.com/knishioka/github-pr-stats/models"
+)
+
+type csvExporter struct{}
+
+// NewCSVExporter returns csvExporter instance as ExportInterface
+func NewCSVExporter() ExportInterface {
+	return &csvExporter{}
+}
+
+// Export writes the per user stats to filename.csv and each other table next
+// to it in filename_<table>.csv: repos, latency, sizes, the reviewer to author matrix,
+// with more than one org the per org breakdown in orgs, with the teams the per
+// team rollups in teams, when bucketed the per
+// bucket stats in buckets, when compared, the change from the baseline
+// window in comparison and, when users were excluded, what was left out in excluded
+func (exp *csvExporter) Export(report *models.Report, filename string) error {
+	tables := map[string]*table{
+		"":         userTable(report),
+		"_repos":   repoTable(report),
+		"_latency": latencyTable(report),
+		"_sizes":   sizeTable(report),
+		"_matrix":  matrixTable(report),
+	}
+
+	if len(report.Orgs) > 1 {
+		tables["_orgs"] = orgTable(report)
+	}
+
+	if report.Teams != nil {
+		tables["_teams"] = teamTable(report)
+	}
+
+	if len(report.Buckets) > 0 {
+		tables["_buckets"] = bucketTable(report)
+	}
+
+	if !report.BaselineEnd.IsZero() {
+		tables["_comparison"] = comparisonTable(report)
+	}
+
+	if !report.Excluded.Empty() {
+		tables["_excluded"] = excludedTable(report)
+	}
+
+	for suffix, t := range tables {
+		if err := writeCSV(filename+suffix+".csv", t); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeCSV writes the header row followed by the rows of t to filename
+func writeCSV(filename string, t *table) error {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening file: %v", err.Error())
+	}
+
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	// write header row
+	if err = writer.Write(t.header); err != nil {
+		return fmt.Errorf("error writing to file: %v", err.Error())
+	}
+
+	// write stats
+	for _, row := range t.rows {
+		record := make([]string, len(row))
+		for i, cell := range row {
+			record[i] = formatCell(cell)
+		}
+
+		err := writer.Write(record)
+		if err != nil {
+			return fmt.Errorf("error writing to file: %v", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// formatCell returns the text of a table cell
+func formatCell(cell interface{}) string {
+	switch v := cell.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+
+		return v.Format(time.RFC3339)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
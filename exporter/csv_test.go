@@ -0,0 +1,38 @@
+package exporter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/knishioka/github-pr-stats/models"
+)
+
+func TestCSVExcluded(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "stats")
+
+	report := &models.Report{}
+	if err := NewCSVExporter().Export(report, filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filename + "_excluded.csv"); !os.IsNotExist(err) {
+		t.Errorf("stats_excluded.csv written without exclusions, stat error = %v", err)
+	}
+
+	report.Excluded = models.Exclusions{Logins: []string{"ci-bot", "dependabot[bot]"}, PullReqs: 4, Reviews: 2, Comments: 1}
+	if err := NewCSVExporter().Export(report, filename); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadFile(filename + "_excluded.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "logins,Pull Requests,Reviews,Comments,Members\nci-bot dependabot[bot],4,2,1,0\n"
+	if string(got) != want {
+		t.Errorf("stats_excluded.csv = %q, want %q", got, want)
+	}
+}
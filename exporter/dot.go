@@ -0,0 +1,53 @@
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/knishioka/github-pr-stats/models"
+)
+
+type dotExporter struct{}
+
+// NewDOTExporter returns dotExporter instance as ExportInterface
+func NewDOTExporter() ExportInterface {
+	return &dotExporter{}
+}
+
+// Export writes the reviewer to author graph to filename.dot in the Graphviz
+// DOT language, an edge goes from a reviewer to an author weighted by the
+// number of reviews
+func (exp *dotExporter) Export(report *models.Report, filename string) error {
+	file, err := os.OpenFile(filename+".dot", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening file: %v", err.Error())
+	}
+
+	defer file.Close()
+
+	kind := "reviews"
+	if report.ApprovalsOnly {
+		kind = "approvals"
+	}
+
+	dateformat := "2006-01-02"
+	w := bufio.NewWriter(file)
+	fmt.Fprintf(w, "digraph %v {\n", kind)
+	fmt.Fprintf(w, "\tlabel=%v;\n", strconv.Quote(fmt.Sprintf("%v from %v to %v",
+		kind, report.Start.Format(dateformat), report.End.Format(dateformat))))
+	fmt.Fprintf(w, "\tnode [shape=box];\n")
+	for _, in := range report.Interactions {
+		fmt.Fprintf(w, "\t%v -> %v [weight=%v, label=\"%v\"];\n",
+			strconv.Quote(in.Reviewer), strconv.Quote(in.Author), in.Reviews, in.Reviews)
+	}
+
+	fmt.Fprintf(w, "}\n")
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error writing to file: %v", err.Error())
+	}
+
+	return nil
+}
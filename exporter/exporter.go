@@ -2,55 +2,52 @@ package exporter
 
 import (
 	"fmt"
-	"os"
-	"strconv"
-
-	"encoding/csv"
+	"strings"
 
 	"github.com/knishioka/github-pr-stats/models"
 )
 
-//ExportInterface defines framework for an exporter
+// ExportInterface defines framework for an exporter
+// The filename is passed without extension, each exporter adds its own
 type ExportInterface interface {
-	Export(map[string]*models.User, string) error
+	Export(*models.Report, string) error
 }
 
-type excelExporter struct{}
-
-//NewExcelExporter returns excelExporter instance as ExportInterface
-func NewExcelExporter() ExportInterface {
-	return &excelExporter{}
-}
-func (exp *excelExporter) Export(stats map[string]*models.User, filename string) error {
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("error opening file: %v", err.Error())
+// New returns the exporter of each format as a single ExportInterface
+// Formats: csv, xlsx, json, ndjson, dot
+func New(formats []string) (ExportInterface, error) {
+	var exporters multiExporter
+	for _, format := range formats {
+		switch strings.ToLower(format) {
+		case "csv":
+			exporters = append(exporters, NewCSVExporter())
+		case "xlsx":
+			exporters = append(exporters, NewExcelExporter())
+		case "json":
+			exporters = append(exporters, NewJSONExporter())
+		case "ndjson":
+			exporters = append(exporters, NewNDJSONExporter())
+		case "dot":
+			exporters = append(exporters, NewDOTExporter())
+		default:
+			return nil, fmt.Errorf("unknown export format: %v", format)
+		}
 	}
 
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	// write header row
-	record := []string{"username", "Pull Requests Created", "Pull Requests Reviewed",
-		"Reviews on Pull Requests", "Additions", "Deletions", "Files Changed", "Total Commits"}
-
-	if err = writer.Write(record); err != nil {
-		return fmt.Errorf("error writing to file: %v", err.Error())
+	if len(exporters) == 1 {
+		return exporters[0], nil
 	}
 
-	// write stats
-	for _, user := range stats {
-		record := []string{user.Username, strconv.Itoa(user.PullReqsCreated), strconv.Itoa(user.PullReqsReviewed),
-			strconv.Itoa(user.ReviewsOnPullReqs), strconv.Itoa(user.TotalAdditions),
-			strconv.Itoa(user.TotalDeletions), strconv.Itoa(user.TotalChangedFiles),
-			strconv.Itoa(user.TotalCommits),
-		}
+	return exporters, nil
+}
+
+// multiExporter exports a report with each of its exporters
+type multiExporter []ExportInterface
 
-		err := writer.Write(record)
-		if err != nil {
-			return fmt.Errorf("error writing to file: %v", err.Error())
+func (exp multiExporter) Export(report *models.Report, filename string) error {
+	for _, e := range exp {
+		if err := e.Export(report, filename); err != nil {
+			return err
 		}
 	}
 
@@ -0,0 +1,300 @@
+package exporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/knishioka/github-pr-stats/models"
+)
+
+// SchemaVersion is the version of the JSON & NDJSON documents,
+// it is bumped whenever a field is removed or changes meaning
+const SchemaVersion = 2
+
+// jsonWindow is the time window the stats cover
+type jsonWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// jsonMeta describes the run a document was generated by
+type jsonMeta struct {
+	SchemaVersion int        `json:"schema_version"`
+	Orgs          []string   `json:"orgs"`
+	Window        jsonWindow `json:"window"`
+	GeneratedAt   time.Time  `json:"generated_at"`
+	// Excluded counts what the exclusion rules left out of the stats
+	Excluded models.Exclusions `json:"excluded"`
+}
+
+// jsonBaseline holds the stats of the baseline window the report is compared to
+// and the change of each user from it
+type jsonBaseline struct {
+	Window  jsonWindow        `json:"window"`
+	Users   []*models.User    `json:"users"`
+	Changes []*jsonUserChange `json:"changes"`
+}
+
+// jsonUserChange is the change of the stats of a user from the baseline window,
+// in absolute & in percent of the baseline, null where the baseline is 0
+type jsonUserChange struct {
+	ID        int64                  `json:"id"`
+	Username  string                 `json:"username"`
+	Change    map[string]float64     `json:"change"`
+	ChangePct map[string]interface{} `json:"change_pct"`
+}
+
+// jsonDocument is the document written by the JSON exporter
+type jsonDocument struct {
+	jsonMeta
+	Users    []*models.User            `json:"users"`
+	Baseline *jsonBaseline             `json:"baseline,omitempty"`
+	OrgUsers map[string][]*models.User `json:"org_users,omitempty"`
+	Repos    []*models.RepoStats       `json:"repos"`
+	Latency  []*models.LatencyStats    `json:"latency"`
+	Sizes    []*models.SizeStats       `json:"sizes"`
+	Teams    []*models.TeamStats       `json:"teams,omitempty"`
+	// Bucket is the size of the Buckets: day, week or month
+	Bucket  string           `json:"bucket,omitempty"`
+	Buckets []*models.Bucket `json:"buckets,omitempty"`
+}
+
+// ndjsonMeta is the first line of the NDJSON export
+type ndjsonMeta struct {
+	Type string `json:"type"`
+	jsonMeta
+}
+
+// ndjsonUser is a "user", "baseline_user" or "org_user" line of the NDJSON export
+type ndjsonUser struct {
+	Type string `json:"type"`
+	Org  string `json:"org,omitempty"`
+	*models.User
+}
+
+// ndjsonUserChange is a "comparison_user" line of the NDJSON export
+type ndjsonUserChange struct {
+	Type string `json:"type"`
+	*jsonUserChange
+}
+
+// ndjsonBucketUser is a "bucket_user" line of the NDJSON export
+type ndjsonBucketUser struct {
+	Type        string    `json:"type"`
+	Bucket      string    `json:"bucket"`
+	BucketStart time.Time `json:"bucket_start"`
+	BucketEnd   time.Time `json:"bucket_end"`
+	*models.User
+}
+
+// ndjsonRepo is a "repo" line of the NDJSON export
+type ndjsonRepo struct {
+	Type string `json:"type"`
+	*models.RepoStats
+}
+
+// ndjsonLatency is a "latency" line of the NDJSON export
+type ndjsonLatency struct {
+	Type string `json:"type"`
+	*models.LatencyStats
+}
+
+// ndjsonTeam is a "team" line of the NDJSON export
+type ndjsonTeam struct {
+	Type string `json:"type"`
+	*models.TeamStats
+}
+
+// ndjsonSize is a "size" line of the NDJSON export
+type ndjsonSize struct {
+	Type string `json:"type"`
+	*models.SizeStats
+}
+
+func newJSONMeta(report *models.Report) jsonMeta {
+	return jsonMeta{
+		SchemaVersion: SchemaVersion,
+		Orgs:          report.Orgs,
+		Window:        jsonWindow{Start: report.Start, End: report.End},
+		GeneratedAt:   report.GeneratedAt,
+		Excluded:      report.Excluded,
+	}
+}
+
+// newJSONUserChanges returns the changes of the comparison table keyed by stat
+func newJSONUserChanges(report *models.Report) []*jsonUserChange {
+	var changes []*jsonUserChange
+	for _, c := range userChanges(report) {
+		change := &jsonUserChange{
+			ID:        c.user.ID,
+			Username:  c.user.Username,
+			Change:    make(map[string]float64),
+			ChangePct: make(map[string]interface{}),
+		}
+
+		for k := 1; k < len(c.row); k++ {
+			change.Change[userKeys[k]] = c.change[k]
+			change.ChangePct[userKeys[k]] = c.percent[k]
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+type jsonExporter struct{}
+
+// NewJSONExporter returns jsonExporter instance as ExportInterface
+func NewJSONExporter() ExportInterface {
+	return &jsonExporter{}
+}
+
+// Export writes the report to filename.json as a single indented document
+func (exp *jsonExporter) Export(report *models.Report, filename string) error {
+	doc := &jsonDocument{
+		jsonMeta: newJSONMeta(report),
+		Users:    report.Users,
+		Repos:    report.Repos,
+		Latency:  report.Latency,
+		Sizes:    report.Sizes,
+		Teams:    report.Teams,
+	}
+
+	if len(report.Orgs) > 1 {
+		doc.OrgUsers = report.OrgUsers
+	}
+
+	if len(report.Buckets) > 0 {
+		doc.Bucket = report.Bucket
+		doc.Buckets = report.Buckets
+	}
+
+	if !report.BaselineEnd.IsZero() {
+		doc.Baseline = &jsonBaseline{
+			Window:  jsonWindow{Start: report.BaselineStart, End: report.BaselineEnd},
+			Users:   report.BaselineUsers,
+			Changes: newJSONUserChanges(report),
+		}
+	}
+
+	return writeJSON(filename+".json", func(enc *json.Encoder) error {
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	})
+}
+
+type ndjsonExporter struct{}
+
+// NewNDJSONExporter returns ndjsonExporter instance as ExportInterface
+func NewNDJSONExporter() ExportInterface {
+	return &ndjsonExporter{}
+}
+
+// Export writes the report to filename.ndjson, the first line is the "meta"
+// record followed by a "user" record per user, when compared a "baseline_user"
+// record per user of the baseline window & a "comparison_user" record per user
+// with the change of their stats from it, with the teams a "team" record per
+// team, a "repo" record per repo,
+// a "latency" record per author, reviewer & repo, a "size" record per PR size
+// class, when bucketed a "bucket_user" record per bucket & user and, with
+// more than one org, an "org_user" record per user & org
+func (exp *ndjsonExporter) Export(report *models.Report, filename string) error {
+	return writeJSON(filename+".ndjson", func(enc *json.Encoder) error {
+		if err := enc.Encode(&ndjsonMeta{Type: "meta", jsonMeta: newJSONMeta(report)}); err != nil {
+			return err
+		}
+
+		for _, user := range report.Users {
+			if err := enc.Encode(&ndjsonUser{Type: "user", User: user}); err != nil {
+				return err
+			}
+		}
+
+		for _, user := range report.BaselineUsers {
+			if err := enc.Encode(&ndjsonUser{Type: "baseline_user", User: user}); err != nil {
+				return err
+			}
+		}
+
+		if !report.BaselineEnd.IsZero() {
+			for _, change := range newJSONUserChanges(report) {
+				if err := enc.Encode(&ndjsonUserChange{Type: "comparison_user", jsonUserChange: change}); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, team := range report.Teams {
+			if err := enc.Encode(&ndjsonTeam{Type: "team", TeamStats: team}); err != nil {
+				return err
+			}
+		}
+
+		for _, repo := range report.Repos {
+			if err := enc.Encode(&ndjsonRepo{Type: "repo", RepoStats: repo}); err != nil {
+				return err
+			}
+		}
+
+		for _, latency := range report.Latency {
+			if err := enc.Encode(&ndjsonLatency{Type: "latency", LatencyStats: latency}); err != nil {
+				return err
+			}
+		}
+
+		for _, size := range report.Sizes {
+			if err := enc.Encode(&ndjsonSize{Type: "size", SizeStats: size}); err != nil {
+				return err
+			}
+		}
+
+		for _, bucket := range report.Buckets {
+			for _, user := range bucket.Users {
+				line := &ndjsonBucketUser{Type: "bucket_user", Bucket: bucket.Label,
+					BucketStart: bucket.Start, BucketEnd: bucket.End, User: user}
+				if err := enc.Encode(line); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(report.Orgs) < 2 {
+			return nil
+		}
+
+		for _, org := range report.Orgs {
+			for _, user := range report.OrgUsers[org] {
+				if err := enc.Encode(&ndjsonUser{Type: "org_user", Org: org, User: user}); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// writeJSON creates filename and hands an encoder writing to it to encode
+func writeJSON(filename string, encode func(enc *json.Encoder) error) error {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening file: %v", err.Error())
+	}
+
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	if err := encode(json.NewEncoder(writer)); err != nil {
+		return fmt.Errorf("error writing to file: %v", err.Error())
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("error writing to file: %v", err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,476 @@
+package exporter
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/knishioka/github-pr-stats/models"
+)
+
+// table is a sheet of stats shared by the exporters,
+// cells are either string, int, float64, time.Time or nil for a blank
+type table struct {
+	name   string
+	header []string
+	rows   [][]interface{}
+}
+
+// userColumns are the header & the models.User field of the columns of userRow
+var userColumns = []struct{ header, field string }{
+	{"username", "Username"}, {"Pull Requests Created", "PullReqsCreated"}, {"Pull Requests Reviewed", "PullReqsReviewed"},
+	{"Review Submissions", "ReviewSubmissions"}, {"Reviews on Pull Requests", "ReviewsOnPullReqs"},
+	{"Additions", "TotalAdditions"}, {"Deletions", "TotalDeletions"}, {"Files Changed", "TotalChangedFiles"},
+	{"Total Commits", "TotalCommits"},
+	{"Merged", "PullReqsMerged"}, {"Closed Unmerged", "PullReqsClosedUnmerged"}, {"Open", "PullReqsOpen"},
+	{"Pull Requests Merged By", "PullReqsMergedBy"},
+	{"Approvals", "ReviewsApproved"}, {"Changes Requested", "ReviewsChangesRequested"},
+	{"Comment Reviews", "ReviewsCommented"}, {"Dismissed Reviews", "ReviewsDismissed"},
+	{"Pull Requests Approved", "PullReqsApproved"}, {"Pull Requests with Changes Requested", "PullReqsChangesRequested"},
+	{"Review Approval Rate", "ReviewApprovalRate"}, {"Pull Request Approval Rate", "PullReqApprovalRate"},
+	{"Comments Written", "CommentsWritten"}, {"Comments Received", "CommentsReceived"},
+	{"Comments per Reviewed Pull Request", "CommentsPerReviewedPullReq"},
+	{"XS Pull Requests", "PullReqsXS"}, {"S Pull Requests", "PullReqsS"}, {"M Pull Requests", "PullReqsM"},
+	{"L Pull Requests", "PullReqsL"}, {"XL Pull Requests", "PullReqsXL"},
+	{"Median Pull Request Size", "MedianPullReqSize"},
+}
+
+var (
+	// userHeader names the columns of userRow
+	userHeader = columnHeaders()
+	// userKeys are the JSON names of the columns of userRow, taken from the
+	// json tags of the models.User fields
+	userKeys = columnKeys()
+)
+
+// columnHeaders returns the headers of userColumns
+func columnHeaders() []string {
+	headers := make([]string, len(userColumns))
+	for i, column := range userColumns {
+		headers[i] = column.header
+	}
+
+	return headers
+}
+
+// columnKeys returns the json tags of the fields of userColumns
+func columnKeys() []string {
+	userType := reflect.TypeOf(models.User{})
+	keys := make([]string, len(userColumns))
+	for i, column := range userColumns {
+		field, ok := userType.FieldByName(column.field)
+		if !ok {
+			panic("exporter: no models.User field " + column.field)
+		}
+
+		keys[i] = strings.Split(field.Tag.Get("json"), ",")[0]
+	}
+
+	return keys
+}
+
+// userRow returns the username & the stats of user
+func userRow(user *models.User) []interface{} {
+	value := reflect.ValueOf(user).Elem()
+	row := make([]interface{}, len(userColumns))
+	for i, column := range userColumns {
+		row[i] = value.FieldByName(column.field).Interface()
+	}
+
+	return row
+}
+
+// userTable returns the stats of each user over all the orgs,
+// along with their teams when the teams were collected
+func userTable(report *models.Report) *table {
+	t := &table{name: "Users", header: userHeader}
+	if report.Teams != nil {
+		t.header = append(append([]string{}, userHeader...), "Teams")
+	}
+
+	for _, user := range report.Users {
+		row := userRow(user)
+		if report.Teams != nil {
+			row = append(row, strings.Join(user.Teams, " "))
+		}
+
+		t.rows = append(t.rows, row)
+	}
+
+	return t
+}
+
+// userChange is the change of the stats of a user from the baseline window,
+// row is the userRow of the user, change & percent are indexed like row and
+// percent is nil where the baseline stat is 0
+type userChange struct {
+	user    *models.User
+	row     []interface{}
+	change  []float64
+	percent []interface{}
+}
+
+// userChanges returns the change of each user active in either window
+// from the baseline window, in the order of the usernames
+func userChanges(report *models.Report) []*userChange {
+	baseline := make(map[int64]*models.User)
+	for _, user := range report.BaselineUsers {
+		baseline[user.ID] = user
+	}
+
+	current := make(map[int64]bool)
+	users := append([]*models.User{}, report.Users...)
+	for _, user := range report.Users {
+		current[user.ID] = true
+	}
+
+	// users only active in the baseline window
+	for _, user := range report.BaselineUsers {
+		if !current[user.ID] {
+			users = append(users, &models.User{ID: user.ID, Username: user.Username})
+		}
+	}
+
+	sort.SliceStable(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+
+	var changes []*userChange
+	for _, user := range users {
+		before := baseline[user.ID]
+		if before == nil {
+			before = &models.User{ID: user.ID, Username: user.Username}
+		}
+
+		now, then := userRow(user), userRow(before)
+		c := &userChange{user: user, row: now, change: make([]float64, len(now)), percent: make([]interface{}, len(now))}
+		for k := 1; k < len(now); k++ {
+			c.change[k] = number(now[k]) - number(then[k])
+			if number(then[k]) != 0 {
+				c.percent[k] = c.change[k] / number(then[k]) * 100
+			}
+		}
+
+		changes = append(changes, c)
+	}
+
+	return changes
+}
+
+// comparisonTable returns the stats of each user next to their change from
+// the baseline window, in absolute and in percent of the baseline
+func comparisonTable(report *models.Report) *table {
+	t := &table{name: "Comparison", header: []string{userHeader[0]}}
+	for _, stat := range userHeader[1:] {
+		t.header = append(t.header, stat, stat+" Change", stat+" Change %")
+	}
+
+	for _, c := range userChanges(report) {
+		row := []interface{}{c.user.Username}
+		for k := 1; k < len(c.row); k++ {
+			row = append(row, c.row[k], c.change[k], c.percent[k])
+		}
+
+		t.rows = append(t.rows, row)
+	}
+
+	return t
+}
+
+// number returns the value of a numeric cell, 0 for any other cell
+func number(cell interface{}) float64 {
+	switch v := cell.(type) {
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	default:
+		return 0
+	}
+}
+
+// orgTable returns the stats of each user per org,
+// along with their teams when the teams were collected
+func orgTable(report *models.Report) *table {
+	t := &table{name: "Orgs", header: append([]string{"org"}, userHeader...)}
+	if report.Teams != nil {
+		t.header = append(t.header, "Teams")
+	}
+
+	for _, org := range report.Orgs {
+		for _, user := range report.OrgUsers[org] {
+			row := append([]interface{}{org}, userRow(user)...)
+			if report.Teams != nil {
+				row = append(row, strings.Join(user.Teams, " "))
+			}
+
+			t.rows = append(t.rows, row)
+		}
+	}
+
+	return t
+}
+
+// teamTable returns the stats of each team rolled up from its members
+func teamTable(report *models.Report) *table {
+	t := &table{name: "Teams", header: append([]string{"org", "team", "name", "members"}, userHeader[1:]...)}
+	for _, team := range report.Teams {
+		t.rows = append(t.rows, append([]interface{}{team.Org, team.Slug, team.Name, team.Members},
+			userRow(team.Stats)[1:]...))
+	}
+
+	return t
+}
+
+// repoTable returns the stats of each repo
+func repoTable(report *models.Report) *table {
+	t := &table{name: "Repos", header: []string{"org", "repo", "Pull Requests Opened", "Reviews",
+		"Additions", "Deletions", "Files Changed", "Total Commits", "Authors", "Reviewers",
+		"XS Pull Requests", "S Pull Requests", "M Pull Requests", "L Pull Requests", "XL Pull Requests",
+		"Median Pull Request Size"}}
+	for _, repo := range report.Repos {
+		t.rows = append(t.rows, []interface{}{repo.Org, repo.Name, repo.PullReqsOpened, repo.Reviews,
+			repo.TotalAdditions, repo.TotalDeletions, repo.TotalChangedFiles, repo.TotalCommits,
+			repo.Authors, repo.Reviewers,
+			repo.PullReqsXS, repo.PullReqsS, repo.PullReqsM, repo.PullReqsL, repo.PullReqsXL,
+			repo.MedianPullReqSize})
+	}
+
+	return t
+}
+
+// excludedTable returns what the exclusion rules left out of the stats
+func excludedTable(report *models.Report) *table {
+	x := report.Excluded
+	return &table{name: "Excluded", header: []string{"logins", "Pull Requests", "Reviews", "Comments", "Members"},
+		rows: [][]interface{}{{strings.Join(x.Logins, " "), x.PullReqs, x.Reviews, x.Comments, x.Members}}}
+}
+
+// pullRequestTable returns the PRs created, reviewed or commented within the window
+func pullRequestTable(report *models.Report) *table {
+	t := &table{name: "Pull Requests", header: []string{"org", "repo", "number", "author", "created at",
+		"updated at", "state", "draft", "merged", "merged by", "merged at", "closed at",
+		"additions", "deletions", "files changed", "commits", "reviews", "comments",
+		"hours to first review", "hours to approval", "hours to merge"}}
+	for _, pr := range report.PullRequests {
+		t.rows = append(t.rows, []interface{}{pr.Org, pr.RepoName, pr.PrNo, pr.Username, pr.CreatedAt,
+			pr.UpdatedAt, pr.State, pr.Draft, pr.Merged, pr.MergedBy, pr.MergedAt, pr.ClosedAt, pr.Additions, pr.Deletions, pr.ChangedFiles, pr.Commits, len(pr.Reviews), len(pr.Comments),
+			hoursSince(pr.CreatedAt, pr.FirstReviewAt()), hoursSince(pr.CreatedAt, pr.ApprovedAt()),
+			hoursSince(pr.CreatedAt, pr.MergedAt)})
+	}
+
+	return t
+}
+
+// hoursSince returns the hours from start to end, blank if end is zero
+func hoursSince(start, end time.Time) interface{} {
+	if end.IsZero() {
+		return nil
+	}
+
+	return end.Sub(start).Hours()
+}
+
+// latencyTable returns a row per author, reviewer & repo and latency metric
+func latencyTable(report *models.Report) *table {
+	t := &table{name: "Latency", header: []string{"scope", "name", "metric", "count",
+		"median hours", "p75 hours", "p90 hours", "max hours"}}
+	for _, stats := range report.Latency {
+		metrics := []struct {
+			name    string
+			latency models.Latency
+		}{
+			{"time to first review", stats.TimeToFirstReview},
+			{"time to approval", stats.TimeToApproval},
+			{"time to merge", stats.TimeToMerge},
+		}
+
+		for _, m := range metrics {
+			if m.latency.Count == 0 {
+				continue
+			}
+
+			t.rows = append(t.rows, []interface{}{stats.Scope, stats.Name, m.name, m.latency.Count,
+				m.latency.Median, m.latency.P75, m.latency.P90, m.latency.Max})
+		}
+	}
+
+	return t
+}
+
+// sizeTable returns a row per PR size class on how its PRs got reviewed
+func sizeTable(report *models.Report) *table {
+	t := &table{name: "Sizes", header: []string{"size", "min lines", "max lines", "pull requests",
+		"reviewed", "reviewed %", "reviews per pull request", "reviewers per pull request",
+		"median hours to first review", "p90 hours to first review",
+		"median hours to merge", "p90 hours to merge"}}
+	for _, size := range report.Sizes {
+		var maxLines, reviewed interface{}
+		if size.MaxLines > 0 {
+			maxLines = size.MaxLines
+		}
+
+		if size.PullReqs > 0 {
+			reviewed = float64(size.Reviewed) / float64(size.PullReqs) * 100
+		}
+
+		t.rows = append(t.rows, []interface{}{size.Class, size.MinLines, maxLines, size.PullReqs,
+			size.Reviewed, reviewed, size.ReviewsPerPullReq, size.ReviewersPerPullReq,
+			latencyCell(size.TimeToFirstReview, size.TimeToFirstReview.Median),
+			latencyCell(size.TimeToFirstReview, size.TimeToFirstReview.P90),
+			latencyCell(size.TimeToMerge, size.TimeToMerge.Median),
+			latencyCell(size.TimeToMerge, size.TimeToMerge.P90)})
+	}
+
+	return t
+}
+
+// latencyCell returns hours of latency, blank if latency has no samples
+func latencyCell(latency models.Latency, hours float64) interface{} {
+	if latency.Count == 0 {
+		return nil
+	}
+
+	return hours
+}
+
+// reviewTable returns the reviews submitted within the window
+func reviewTable(report *models.Report) *table {
+	t := &table{name: "Reviews", header: []string{"org", "repo", "number", "author", "reviewer",
+		"state", "submitted at"}}
+	for _, pr := range report.PullRequests {
+		for _, review := range pr.Reviews {
+			if !report.InWindow(review.SubmittedAt) {
+				continue
+			}
+
+			t.rows = append(t.rows, []interface{}{pr.Org, pr.RepoName, pr.PrNo, pr.Username,
+				review.Username, review.State, review.SubmittedAt})
+		}
+	}
+
+	return t
+}
+
+// commentTable returns the comments written within the window
+func commentTable(report *models.Report) *table {
+	t := &table{name: "Comments", header: []string{"org", "repo", "number", "author", "commenter",
+		"kind", "created at"}}
+	for _, pr := range report.PullRequests {
+		for _, comment := range pr.Comments {
+			if !report.InWindow(comment.CreatedAt) {
+				continue
+			}
+
+			t.rows = append(t.rows, []interface{}{pr.Org, pr.RepoName, pr.PrNo, pr.Username,
+				comment.Username, comment.Kind, comment.CreatedAt})
+		}
+	}
+
+	return t
+}
+
+// matrixTable returns the reviewer to author matrix: a row per reviewer and a
+// column per author, over every user found in the interactions
+func matrixTable(report *models.Report) *table {
+	var names []string
+	seen := make(map[string]bool)
+	counts := make(map[[2]string]int)
+	for _, in := range report.Interactions {
+		for _, name := range []string{in.Reviewer, in.Author} {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+
+		counts[[2]string{in.Reviewer, in.Author}] += in.Reviews
+	}
+
+	sort.Strings(names)
+
+	t := &table{name: "Review Matrix", header: append([]string{"reviewer \\ author"}, names...)}
+	if report.ApprovalsOnly {
+		t.name = "Approval Matrix"
+	}
+
+	for _, reviewer := range names {
+		row := []interface{}{reviewer}
+		for _, author := range names {
+			row = append(row, counts[[2]string{reviewer, author}])
+		}
+
+		t.rows = append(t.rows, row)
+	}
+
+	return t
+}
+
+// bucketTable returns the stats of each user per bucket, in the long layout a
+// row per bucket & user, in the wide layout a row per user with a column per
+// stat & bucket
+func bucketTable(report *models.Report) *table {
+	if report.BucketFormat == "wide" {
+		return wideBucketTable(report)
+	}
+
+	t := &table{name: "Buckets", header: append([]string{"bucket", "bucket start", "bucket end"}, userHeader...)}
+	for _, bucket := range report.Buckets {
+		for _, user := range bucket.Users {
+			t.rows = append(t.rows, append([]interface{}{bucket.Label, bucket.Start, bucket.End}, userRow(user)...))
+		}
+	}
+
+	return t
+}
+
+// wideBucketTable returns the stats of each user with a column per stat &
+// bucket, the users are matched by their github user ID across the buckets
+func wideBucketTable(report *models.Report) *table {
+	t := &table{name: "Buckets", header: []string{userHeader[0]}}
+	for _, stat := range userHeader[1:] {
+		for _, bucket := range report.Buckets {
+			t.header = append(t.header, stat+" "+bucket.Label)
+		}
+	}
+
+	var users []*models.User
+	perBucket := make(map[int64][]*models.User)
+	for i, bucket := range report.Buckets {
+		for _, user := range bucket.Users {
+			if perBucket[user.ID] == nil {
+				perBucket[user.ID] = make([]*models.User, len(report.Buckets))
+				users = append(users, user)
+			}
+
+			perBucket[user.ID][i] = user
+		}
+	}
+
+	sort.SliceStable(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+
+	for _, user := range users {
+		rows := make([][]interface{}, len(report.Buckets))
+		for i, stats := range perBucket[user.ID] {
+			if stats == nil {
+				stats = &models.User{ID: user.ID, Username: user.Username}
+			}
+
+			rows[i] = userRow(stats)
+		}
+
+		row := []interface{}{user.Username}
+		for k := 1; k < len(userHeader); k++ {
+			for i := range rows {
+				row = append(row, rows[i][k])
+			}
+		}
+
+		t.rows = append(t.rows, row)
+	}
+
+	return t
+}
@@ -0,0 +1,46 @@
+package exporter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/knishioka/github-pr-stats/models"
+)
+
+func TestUserColumns(t *testing.T) {
+	row := userRow(&models.User{Username: "alice", ReviewsOnPullReqs: 3, SizeCounts: models.SizeCounts{PullReqsXL: 2}})
+	if len(userKeys) != len(userHeader) || len(row) != len(userHeader) {
+		t.Fatalf("%v keys, %v cells, want %v like userHeader", len(userKeys), len(row), len(userHeader))
+	}
+
+	// every stat of models.User is a column, named by its json tag
+	var tags []string
+	var walk func(reflect.Type)
+	walk = func(typ reflect.Type) {
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.Anonymous {
+				walk(field.Type)
+				continue
+			}
+
+			if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag != "id" && tag != "teams" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	walk(reflect.TypeOf(models.User{}))
+
+	if !reflect.DeepEqual(userKeys, tags) {
+		t.Errorf("userKeys = %v, want the json tags %v", userKeys, tags)
+	}
+
+	cells := make(map[string]interface{})
+	for i, key := range userKeys {
+		cells[key] = row[i]
+	}
+	if cells["username"] != "alice" || cells["reviews_on_pull_reqs"] != 3 || cells["pull_reqs_xl"] != 2 {
+		t.Errorf("userRow() = %v", cells)
+	}
+}
@@ -0,0 +1,164 @@
+package exporter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/knishioka/github-pr-stats/models"
+	"github.com/xuri/excelize/v2"
+)
+
+// maxColWidth caps the width of the xlsx columns, in characters
+const maxColWidth = 50
+
+type excelExporter struct{}
+
+// NewExcelExporter returns excelExporter instance as ExportInterface
+func NewExcelExporter() ExportInterface {
+	return &excelExporter{}
+}
+
+// Export writes the report to filename.xlsx with a sheet per table
+func (exp *excelExporter) Export(report *models.Report, filename string) error {
+	tables := []*table{userTable(report)}
+	if len(report.Orgs) > 1 {
+		tables = append(tables, orgTable(report))
+	}
+
+	if report.Teams != nil {
+		tables = append(tables, teamTable(report))
+	}
+
+	if !report.BaselineEnd.IsZero() {
+		tables = append(tables, comparisonTable(report))
+	}
+
+	if len(report.Buckets) > 0 {
+		tables = append(tables, bucketTable(report))
+	}
+
+	tables = append(tables, repoTable(report), latencyTable(report), sizeTable(report), matrixTable(report),
+		pullRequestTable(report), reviewTable(report), commentTable(report))
+	if !report.Excluded.Empty() {
+		tables = append(tables, excludedTable(report))
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	headerStyle, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return fmt.Errorf("error creating xlsx style: %v", err.Error())
+	}
+
+	for i, t := range tables {
+		if i == 0 {
+			err = f.SetSheetName(f.GetSheetName(0), t.name)
+		} else {
+			_, err = f.NewSheet(t.name)
+		}
+		if err != nil {
+			return fmt.Errorf("error creating sheet %v: %v", t.name, err.Error())
+		}
+
+		if err := writeSheet(f, t, headerStyle); err != nil {
+			return fmt.Errorf("error writing sheet %v: %v", t.name, err.Error())
+		}
+	}
+
+	if err := f.SaveAs(filename + ".xlsx"); err != nil {
+		return fmt.Errorf("error writing to file: %v", err.Error())
+	}
+
+	return nil
+}
+
+// writeSheet streams t to its sheet with a frozen, filterable header row
+func writeSheet(f *excelize.File, t *table, headerStyle int) error {
+	sw, err := f.NewStreamWriter(t.name)
+	if err != nil {
+		return err
+	}
+
+	// widths must be set before the first row
+	for col, width := range colWidths(t) {
+		if err := sw.SetColWidth(col+1, col+1, width); err != nil {
+			return err
+		}
+	}
+
+	err = sw.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+	if err != nil {
+		return err
+	}
+
+	header := make([]interface{}, len(t.header))
+	for i, name := range t.header {
+		header[i] = excelize.Cell{StyleID: headerStyle, Value: name}
+	}
+
+	if err := sw.SetRow("A1", header); err != nil {
+		return err
+	}
+
+	for i, row := range t.rows {
+		cells := make([]interface{}, len(row))
+		for j, cell := range row {
+			// leave zero dates blank rather than writing 1900-01-00
+			if v, ok := cell.(time.Time); ok && v.IsZero() {
+				cell = nil
+			}
+			cells[j] = cell
+		}
+
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return err
+		}
+
+		if err := sw.SetRow(cell, cells); err != nil {
+			return err
+		}
+	}
+
+	// the filter goes in the worksheet written out by Flush
+	last, err := excelize.CoordinatesToCellName(len(t.header), len(t.rows)+1)
+	if err != nil {
+		return err
+	}
+
+	if err := f.AutoFilter(t.name, "A1:"+last, nil); err != nil {
+		return err
+	}
+
+	return sw.Flush()
+}
+
+// colWidths fits each column of t to its longest value, up to maxColWidth
+func colWidths(t *table) []float64 {
+	widths := make([]float64, len(t.header))
+	for i, name := range t.header {
+		widths[i] = float64(len(name)) + 2
+	}
+
+	for _, row := range t.rows {
+		for i, cell := range row {
+			width := float64(len(formatCell(cell))) + 2
+			if _, ok := cell.(time.Time); ok {
+				width = 18
+			}
+
+			if i < len(widths) && width > widths[i] {
+				widths[i] = width
+			}
+		}
+	}
+
+	for i := range widths {
+		if widths[i] > maxColWidth {
+			widths[i] = maxColWidth
+		}
+	}
+
+	return widths
+}
@@ -0,0 +1,19 @@
+package gitutil
+
+import "github.com/google/go-github/github"
+
+// The detail responses embed the go-github types along with the fields
+// go-github predates
+type (
+	// repositoryDetail is the repo response
+	repositoryDetail struct {
+		github.Repository
+		Visibility string `json:"visibility"`
+	}
+
+	// pullRequestDetail is the PR detail response
+	pullRequestDetail struct {
+		github.PullRequest
+		Draft bool `json:"draft"`
+	}
+)
@@ -3,15 +3,17 @@ package gitutil
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/google/go-github/github"
+	"github.com/knishioka/github-pr-stats/cache"
 	"github.com/knishioka/github-pr-stats/models"
 	"github.com/knishioka/github-pr-stats/token"
+	"github.com/knishioka/github-pr-stats/transport"
 )
 
 // GitHelper represents Github API Helper
@@ -19,47 +21,130 @@ type GitHelper interface {
 	GetOrgRepos(token.InsTokenInterface) ([]*models.Repo, error)
 	GetOrgMembers(token.InsTokenInterface) ([]*models.User, error)
 	GetPullRequests([]*models.Repo, token.InsTokenInterface) ([]*models.PullRequest, error)
+	GetOrgTeams(token.InsTokenInterface) ([]*models.Team, error)
 	SetBase(time.Time)
+	SetSince(time.Time)
+}
+
+// Options configures a GithubClient
+type Options struct {
+	// BaseURL is the API root, e.g. https://github.example.com/api/v3
+	// for Github Enterprise Server, defaults to transport.DefaultBaseURL
+	BaseURL string
+	// Client makes the API requests, see transport.NewClient
+	Client *http.Client
+	// Concurrency is the max number of PR detail/review fetches in flight
+	Concurrency int
+	// Cache stores responses for conditional requests, disabled if nil
+	Cache *cache.Disk
+	// RepoFilter selects the repos to get the PRs of, every repo if nil
+	RepoFilter *RepoFilter
+	// SkipComments doesn't get the comments of the PRs
+	SkipComments bool
 }
 
 // GithubClient implements GitHelper
 type GithubClient struct {
-	c    *http.Client
-	base time.Time
+	c            *http.Client
+	baseURL      string
+	ctx          context.Context
+	base         time.Time
+	since        time.Time
+	concurrency  int
+	cache        *cache.Disk
+	repoFilter   *RepoFilter
+	skipComments bool
 }
 
 // NewGithubClient returns a GitHelper
-func NewGithubClient(ctx context.Context) GitHelper {
+func NewGithubClient(ctx context.Context, opts Options) GitHelper {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+
+	if opts.Client == nil {
+		opts.Client, _ = transport.NewClient(transport.Options{})
+	}
+
 	return &GithubClient{
-		c: &http.Client{
-			Timeout: time.Second * 23,
-		},
+		c:            opts.Client,
+		baseURL:      baseURL(opts),
+		ctx:          ctx,
+		concurrency:  opts.Concurrency,
+		cache:        opts.Cache,
+		repoFilter:   opts.RepoFilter,
+		skipComments: opts.SkipComments,
 	}
 }
 
+// baseURL returns the API root set in opts without its trailing slash
+func baseURL(opts Options) string {
+	if opts.BaseURL == "" {
+		return transport.DefaultBaseURL
+	}
+
+	return strings.TrimSuffix(opts.BaseURL, "/")
+}
+
+// withContext returns a shallow copy of the client whose requests use ctx
+func (h *GithubClient) withContext(ctx context.Context) *GithubClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
 //Github API Docs: https://developer.github.com/v3/repos/#list-organization-repositories
 func (h *GithubClient) getOrgReposURL(orgName string) string {
-	return fmt.Sprintf("https://api.github.com/orgs/%v/repos?per_page=100", orgName)
+	return fmt.Sprintf("%v/orgs/%v/repos?per_page=100", h.baseURL, orgName)
+}
+
+//Github API Docs: https://developer.github.com/v3/repos/#get
+func (h *GithubClient) getRepoURL(orgName, repoName string) string {
+	return fmt.Sprintf("%v/repos/%v/%v", h.baseURL, orgName, repoName)
 }
 
 //Github API Docs: https://developer.github.com/v3/orgs/members/
 func (h *GithubClient) getOrgMembersURL(orgName string) string {
-	return fmt.Sprintf("https://api.github.com/orgs/%v/members?per_page=100", orgName)
+	return fmt.Sprintf("%v/orgs/%v/members?per_page=100", h.baseURL, orgName)
+}
+
+//Github API Docs: https://developer.github.com/v3/teams/#list-teams
+func (h *GithubClient) getOrgTeamsURL(orgName string) string {
+	return fmt.Sprintf("%v/orgs/%v/teams?per_page=100", h.baseURL, orgName)
+}
+
+//Github API Docs: https://developer.github.com/v3/teams/members/#list-team-members
+func (h *GithubClient) getTeamMembersURL(teamID int64) string {
+	return fmt.Sprintf("%v/teams/%v/members?per_page=100", h.baseURL, teamID)
 }
 
 //Github API Docs: https://developer.github.com/v3/pulls/#list-pull-requests
 func (h *GithubClient) getRepoPrsURL(orgName, repoName string) string {
-	return fmt.Sprintf("https://api.github.com/repos/%v/%v/pulls?state=all&per_page=20", orgName, repoName)
+	if !h.since.IsZero() {
+		return fmt.Sprintf("%v/repos/%v/%v/pulls?state=all&sort=updated&direction=desc&per_page=20", h.baseURL, orgName, repoName)
+	}
+
+	return fmt.Sprintf("%v/repos/%v/%v/pulls?state=all&per_page=20", h.baseURL, orgName, repoName)
 }
 
 //Github API Docs:https://developer.github.com/v3/pulls/reviews/#list-reviews-for-a-pull-request
 func (h *GithubClient) getPrReviewsURL(orgName string, repoName string, prNo int) string {
-	return fmt.Sprintf("https://api.github.com/repos/%v/%v/pulls/%v/reviews?per_page=100", orgName, repoName, prNo)
+	return fmt.Sprintf("%v/repos/%v/%v/pulls/%v/reviews?per_page=100", h.baseURL, orgName, repoName, prNo)
+}
+
+//Github API Docs:https://developer.github.com/v3/pulls/comments/#list-comments-on-a-pull-request
+func (h *GithubClient) getPrReviewCommentsURL(orgName string, repoName string, prNo int) string {
+	return fmt.Sprintf("%v/repos/%v/%v/pulls/%v/comments?per_page=100", h.baseURL, orgName, repoName, prNo)
+}
+
+//Github API Docs:https://developer.github.com/v3/issues/comments/#list-comments-on-an-issue
+func (h *GithubClient) getPrIssueCommentsURL(orgName string, repoName string, prNo int) string {
+	return fmt.Sprintf("%v/repos/%v/%v/issues/%v/comments?per_page=100", h.baseURL, orgName, repoName, prNo)
 }
 
 //Github API Docs:https://developer.github.com/v3/pulls/#get-a-pull-request
 func (h *GithubClient) getPrDetailURL(orgName string, repoName string, prNo int) string {
-	return fmt.Sprintf("https://api.github.com/repos/%v/%v/pulls/%v", orgName, repoName, prNo)
+	return fmt.Sprintf("%v/repos/%v/%v/pulls/%v", h.baseURL, orgName, repoName, prNo)
 }
 
 //SetBase sets the base date
@@ -67,160 +152,136 @@ func (h *GithubClient) SetBase(base time.Time) {
 	h.base = base
 }
 
-func paginate(uri string, i int) string {
-	return fmt.Sprintf("%v&page=%v", uri, i)
+//SetSince lists the PRs by last update down to the ones not updated since the given date
+//instead of by creation down to the base date, unless it is zero
+func (h *GithubClient) SetSince(since time.Time) {
+	h.since = since
 }
 
 //GetAllUsers traverse through the API pagination & returns all the users
 func (h *GithubClient) GetAllUsers(uri string, ita token.InsTokenInterface) (users []*github.User, err error) {
-	i := 1
-	for {
-		var members []*github.User
-		body, err := h.Get(paginate(uri, i), ita)
-		if err != nil {
-			return nil, err
-		}
-
-		if err := json.Unmarshal(body, &members); err != nil {
-			return nil, err
-		}
-
-		users = append(users, members...)
-
-		if len(members) < 99 {
-			break
-		}
-
-		i++
-	}
-
-	return users, nil
+	return paginate[*github.User](h, uri, ita, nil)
 }
 
 //GetAllPullRequests traverse through the API pagination & returns all the Pull Requests
+//It stops after the first page reaching the PRs created before the base date,
+//or not updated since the since date when set
 func (h *GithubClient) GetAllPullRequests(uri string, ita token.InsTokenInterface) (pullReqs []*github.PullRequest, err error) {
-	i := 1
-	for {
-		var prs []*github.PullRequest
-		body, err := h.Get(paginate(uri, i), ita)
-		if err != nil {
-			return nil, err
+	return paginate(h, uri, ita, func(prs []*github.PullRequest) bool {
+		if !h.since.IsZero() {
+			updatedAt := prs[len(prs)-1].UpdatedAt
+			return updatedAt != nil && !h.since.Before(*updatedAt)
 		}
 
-		if err := json.Unmarshal(body, &prs); err != nil {
-			return nil, err
-		}
+		createdAt := prs[len(prs)-1].CreatedAt
+		return createdAt != nil && !h.base.Before(*createdAt)
+	})
+}
 
-		pullReqs = append(pullReqs, prs...)
+//GetAllReviews traverse through the API pagination & returns all the Reviews on a Pull Request
+func (h *GithubClient) GetAllReviews(uri string, ita token.InsTokenInterface) (reviews []*github.PullRequestReview, err error) {
+	return paginate[*github.PullRequestReview](h, uri, ita, nil)
+}
 
-		if len(prs) < 19 {
-			break
-		}
+//GetAllReviewComments traverse through the API pagination & returns all the inline comments on a Pull Request
+func (h *GithubClient) GetAllReviewComments(uri string, ita token.InsTokenInterface) (comments []*github.PullRequestComment, err error) {
+	return paginate[*github.PullRequestComment](h, uri, ita, nil)
+}
 
-		createdAt := prs[len(prs)-1].CreatedAt
-		if createdAt != nil {
-			if !h.base.Before(*createdAt) {
-				break
-			}
-		}
+//GetAllIssueComments traverse through the API pagination & returns all the conversation comments on a Pull Request
+func (h *GithubClient) GetAllIssueComments(uri string, ita token.InsTokenInterface) (comments []*github.IssueComment, err error) {
+	return paginate[*github.IssueComment](h, uri, ita, nil)
+}
 
-		i++
-	}
+//GetAllRepos traverse through the API pagination & returns all the repos
+func (h *GithubClient) GetAllRepos(uri string, ita token.InsTokenInterface) (repos []*github.Repository, err error) {
+	return paginate[*github.Repository](h, uri, ita, nil)
+}
 
-	return pullReqs, nil
+// Get returns bytes given a URL
+func (h *GithubClient) Get(uri string, ita token.InsTokenInterface) (body []byte, err error) {
+	body, _, err = h.getPage(uri, ita)
+	return body, err
 }
 
-//GetAllReviews traverse through the API pagination & returns all the Reviews on a Pull Request
-func (h *GithubClient) GetAllReviews(uri string, ita token.InsTokenInterface) (reviews []*github.PullRequestReview, err error) {
-	i := 1
-	for {
-		var revs []*github.PullRequestReview
-		body, err := h.Get(paginate(uri, i), ita)
-		if err != nil {
-			return nil, err
-		}
+// getPage returns bytes given a URL along with the URL of the next page
+// taken from the Link header, next is empty on the last page.
+// With a cache the request is made conditional and a 304 is served from it.
+func (h *GithubClient) getPage(uri string, ita token.InsTokenInterface) (body []byte, next string, err error) {
+	req, err := http.NewRequestWithContext(h.ctx, "GET", uri, &bytes.Buffer{})
+	if err != nil {
+		return body, next, fmt.Errorf("create new HTTP request: %v: %v", uri, err.Error())
+	}
 
-		if err := json.Unmarshal(body, &revs); err != nil {
-			return nil, err
-		}
+	bearer := ita.Bearer()
+	accept := "application/vnd.github.machine-man-preview+json"
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %v", bearer))
+	req.Header.Add("Accept", accept)
 
-		reviews = append(reviews, revs...)
+	var cached *cache.Entry
+	key := cache.Key(ita.AccountName(), accept, uri)
+	if h.cache != nil {
+		cached = h.cache.Get(key)
+	}
 
-		if len(revs) < 99 {
-			break
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
 		}
 
-		i++
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
 	}
 
-	return reviews, nil
-}
+	data, err := h.c.Do(req)
+	if err != nil {
+		return body, next, fmt.Errorf("make request error:%v: %v", uri, err.Error())
+	}
+	defer data.Body.Close()
 
-//GetAllRepos traverse through the API pagination & returns all the repos
-func (h *GithubClient) GetAllRepos(uri string, ita token.InsTokenInterface) (repos []*github.Repository, err error) {
-	i := 1
-	for {
-		var rep []*github.Repository
-		body, err := h.Get(paginate(uri, i), ita)
+	if data.StatusCode == 401 {
+		err := ita.Renew(bearer)
 		if err != nil {
-			return nil, err
+			return body, next, fmt.Errorf("get installation token: %v", err.Error())
 		}
 
-		if err := json.Unmarshal(body, &rep); err != nil {
-			return nil, err
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", ita.Bearer()))
+		data, err = h.c.Do(req)
+		if err != nil {
+			return body, next, fmt.Errorf("retry with fresh token make request error:%v: %v", uri, err.Error())
 		}
+		defer data.Body.Close()
 
-		repos = append(repos, rep...)
-		if len(rep) < 99 {
-			break
+		if data.StatusCode != 200 && data.StatusCode != 304 {
+			return body, next, fmt.Errorf("retry with fresh token make request error: unexpected response status %v", data.StatusCode)
 		}
-
-		i++
 	}
 
-	return repos, nil
-}
-
-// Get returns bytes given a URL
-func (h *GithubClient) Get(uri string, ita token.InsTokenInterface) (body []byte, err error) {
-	req, err := http.NewRequest("GET", uri, &bytes.Buffer{})
-	if err != nil {
-		return body, fmt.Errorf("create new HTTP request: %v: %v", uri, err.Error())
+	if data.StatusCode == 304 && cached != nil {
+		return cached.Body, transport.NextPageURL(cached.Link), nil
 	}
 
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %v", ita.Bearer()))
-	req.Header.Add("Accept", "application/vnd.github.machine-man-preview+json")
-
-	data, err := h.c.Do(req)
-	if err != nil {
-		return body, fmt.Errorf("make request error:%v: %v", uri, err.Error())
-	}
-	defer data.Body.Close()
-
 	if data.StatusCode != 200 {
-		if data.StatusCode == 401 {
-			err := ita.GenerateNew()
-			if err != nil {
-				return body, fmt.Errorf("get installation token: %v", err.Error())
-			}
-
-			req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", ita.Bearer()))
-			data, err = h.c.Do(req)
-			if err != nil {
-				return body, fmt.Errorf("retry with fresh token make request error:%v: %v", uri, err.Error())
-			}
-			if data.StatusCode != 200 {
-				return body, fmt.Errorf("retry with fresh token make request error: unexpected response status %v", data.StatusCode)
-			}
-		} else {
-			return body, fmt.Errorf("make request : %v error: unexpected response status %v", uri, data.StatusCode)
-		}
+		return body, next, fmt.Errorf("make request : %v error: unexpected response status %v", uri, data.StatusCode)
 	}
 
 	body, err = ioutil.ReadAll(data.Body)
 	if err != nil {
-		return body, fmt.Errorf("read body error: %v", err.Error())
+		return body, next, fmt.Errorf("read body error: %v", err.Error())
+	}
+
+	if h.cache != nil && (data.Header.Get("ETag") != "" || data.Header.Get("Last-Modified") != "") {
+		err := h.cache.Set(key, &cache.Entry{
+			ETag:         data.Header.Get("ETag"),
+			LastModified: data.Header.Get("Last-Modified"),
+			Link:         data.Header.Get("Link"),
+			Body:         body,
+		})
+		if err != nil {
+			return body, next, fmt.Errorf("cache response: %v: %v", uri, err.Error())
+		}
 	}
 
-	return body, nil
+	return body, transport.NextPageURL(data.Header.Get("Link")), nil
 }
@@ -0,0 +1,363 @@
+package gitutil
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/knishioka/github-pr-stats/cache"
+	"github.com/knishioka/github-pr-stats/models"
+)
+
+// testAgent is a token agent whose GenerateNew & Renew hand out the fresh token
+type testAgent struct {
+	mutex     sync.Mutex
+	account   string
+	bearer    string
+	generated int
+}
+
+func (a *testAgent) AccountName() string {
+	if a.account == "" {
+		return "org"
+	}
+
+	return a.account
+}
+
+func (a *testAgent) Bearer() string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.bearer
+}
+
+func (a *testAgent) GenerateNew() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.generated++
+	a.bearer = "fresh"
+	return nil
+}
+
+func (a *testAgent) Renew(rejected string) error {
+	if a.Bearer() != rejected {
+		return nil
+	}
+
+	return a.GenerateNew()
+}
+
+// pagedServer serves pages 1 to pages of /items with an ETag each, answering
+// 304 without a Link header to matching If-None-Match, and 401 to a stale token
+type pagedServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	statuses map[int]int
+	matched  []string
+}
+
+func newPagedServer(t *testing.T, pages int) *pagedServer {
+	s := &pagedServer{statuses: make(map[int]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+
+		status := http.StatusOK
+		etag := fmt.Sprintf(`"v%v"`, page)
+		switch {
+		case r.Header.Get("Authorization") == "Bearer stale":
+			status = http.StatusUnauthorized
+		case r.Header.Get("If-None-Match") == etag:
+			status = http.StatusNotModified
+		}
+
+		s.mutex.Lock()
+		s.statuses[status]++
+		s.matched = append(s.matched, r.Header.Get("If-None-Match"))
+		s.mutex.Unlock()
+
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+
+		if page < pages {
+			w.Header().Set("Link", fmt.Sprintf(`<%v/items?page=%v>; rel="next"`, s.URL, page+1))
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprintf(w, `[%v]`, page)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *pagedServer) reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.statuses = make(map[int]int)
+	s.matched = nil
+}
+
+func TestGetPageConditional(t *testing.T) {
+	const pages = 8
+	srv := newPagedServer(t, pages)
+	disk, err := cache.NewDisk(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewGithubClient(context.Background(), Options{BaseURL: srv.URL, Cache: disk}).(*GithubClient)
+	agent := &testAgent{bearer: "valid"}
+	want := fmt.Sprint([]int{1, 2, 3, 4, 5, 6, 7, 8})
+
+	// the first run fills the cache
+	items, err := paginate[int](h, srv.URL+"/items", agent, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(items) != want {
+		t.Fatalf("first run items = %v, want %v", items, want)
+	}
+	for _, etag := range srv.matched {
+		if etag != "" {
+			t.Errorf("first run sent If-None-Match %v", etag)
+		}
+	}
+
+	// the second run gets 304s, serves the bodies & follows the cached Links
+	srv.reset()
+	items, err = paginate[int](h, srv.URL+"/items", agent, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(items) != want {
+		t.Fatalf("second run items = %v, want %v", items, want)
+	}
+	if srv.statuses[http.StatusNotModified] != pages || len(srv.statuses) != 1 {
+		t.Errorf("second run statuses = %v, want %v 304s", srv.statuses, pages)
+	}
+	for i, etag := range srv.matched {
+		if etag != fmt.Sprintf(`"v%v"`, i+1) {
+			t.Errorf("request %v sent If-None-Match %q", i+1, etag)
+		}
+	}
+
+	// a rejected token is renewed once and the retry is still conditional
+	srv.reset()
+	agent = &testAgent{bearer: "stale"}
+	items, err = paginate[int](h, srv.URL+"/items", agent, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(items) != want {
+		t.Fatalf("renewed run items = %v, want %v", items, want)
+	}
+	if agent.generated != 1 {
+		t.Errorf("GenerateNew called %v times, want 1", agent.generated)
+	}
+	if srv.statuses[http.StatusUnauthorized] != 1 || srv.statuses[http.StatusNotModified] != pages {
+		t.Errorf("renewed run statuses = %v, want one 401 & %v 304s", srv.statuses, pages)
+	}
+
+	// the responses cached for the org aren't served to another account
+	srv.reset()
+	agent = &testAgent{account: "other", bearer: "valid"}
+	if _, err := paginate[int](h, srv.URL+"/items", agent, nil); err != nil {
+		t.Fatal(err)
+	}
+	if srv.statuses[http.StatusOK] != pages || len(srv.statuses) != 1 {
+		t.Errorf("other account statuses = %v, want %v 200s", srv.statuses, pages)
+	}
+}
+
+func TestGetPageWithoutCache(t *testing.T) {
+	srv := newPagedServer(t, 3)
+	h := NewGithubClient(context.Background(), Options{BaseURL: srv.URL}).(*GithubClient)
+	agent := &testAgent{bearer: "valid"}
+
+	for run := 0; run < 2; run++ {
+		items, err := paginate[int](h, srv.URL+"/items", agent, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(items) != "[1 2 3]" {
+			t.Fatalf("items = %v, want [1 2 3]", items)
+		}
+	}
+
+	if srv.statuses[http.StatusOK] != 6 || len(srv.statuses) != 1 {
+		t.Errorf("statuses = %v, want 6 200s", srv.statuses)
+	}
+}
+
+func TestGetPageTokenRejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	h := NewGithubClient(context.Background(), Options{BaseURL: srv.URL}).(*GithubClient)
+	agent := &testAgent{bearer: "stale"}
+	if _, err := h.Get(srv.URL+"/items", agent); err == nil {
+		t.Fatal("Get() error = nil, want the 401 of the retry")
+	}
+
+	if agent.generated != 1 {
+		t.Errorf("GenerateNew called %v times, want 1", agent.generated)
+	}
+}
+
+func TestGetPullRequestSkipComments(t *testing.T) {
+	var mutex sync.Mutex
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		paths = append(paths, r.URL.Path)
+		mutex.Unlock()
+
+		if strings.HasSuffix(r.URL.Path, "/pulls/1") {
+			fmt.Fprint(w, `{"number": 1}`)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	}))
+	defer srv.Close()
+
+	job := prJob{repo: &models.Repo{ID: 1, Name: "repo"}, pr: &github.PullRequest{Number: github.Int(1)}}
+	for _, skip := range []bool{false, true} {
+		paths = nil
+		h := NewGithubClient(context.Background(), Options{BaseURL: srv.URL, SkipComments: skip}).(*GithubClient)
+		if _, err := h.getPullRequest(job, &testAgent{bearer: "valid"}); err != nil {
+			t.Fatal(err)
+		}
+
+		want := "/repos/org/repo/pulls/1 /repos/org/repo/pulls/1/reviews"
+		if !skip {
+			want += " /repos/org/repo/pulls/1/comments /repos/org/repo/issues/1/comments"
+		}
+		if got := strings.Join(paths, " "); got != want {
+			t.Errorf("SkipComments %v: requests = %v, want %v", skip, got, want)
+		}
+	}
+}
+
+func TestURLBuildersKeepBaseURLPrefix(t *testing.T) {
+	h := NewGithubClient(context.Background(), Options{BaseURL: "https://ghe.example.com/api/v3/"}).(*GithubClient)
+	h.SetSince(time.Now())
+	urls := map[string]string{
+		"getOrgReposURL":         h.getOrgReposURL("org"),
+		"getRepoURL":             h.getRepoURL("org", "repo"),
+		"getOrgMembersURL":       h.getOrgMembersURL("org"),
+		"getOrgTeamsURL":         h.getOrgTeamsURL("org"),
+		"getTeamMembersURL":      h.getTeamMembersURL(7),
+		"getRepoPrsURL":          h.getRepoPrsURL("org", "repo"),
+		"getPrReviewsURL":        h.getPrReviewsURL("org", "repo", 1),
+		"getPrReviewCommentsURL": h.getPrReviewCommentsURL("org", "repo", 1),
+		"getPrIssueCommentsURL":  h.getPrIssueCommentsURL("org", "repo", 1),
+		"getPrDetailURL":         h.getPrDetailURL("org", "repo", 1),
+	}
+
+	for name, uri := range urls {
+		if !strings.HasPrefix(uri, "https://ghe.example.com/api/v3/") || strings.Contains(strings.TrimPrefix(uri, "https://"), "//") {
+			t.Errorf("%v = %v, want it under https://ghe.example.com/api/v3/", name, uri)
+		}
+	}
+}
+
+func TestGraphQLURL(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    string
+	}{
+		{baseURL: "", want: "https://api.github.com/graphql"},
+		{baseURL: "https://api.github.com", want: "https://api.github.com/graphql"},
+		{baseURL: "https://ghe.example.com/api/v3", want: "https://ghe.example.com/api/graphql"},
+		{baseURL: "https://ghe.example.com/api/v3/", want: "https://ghe.example.com/api/graphql"},
+	}
+
+	for _, tt := range tests {
+		h := NewGraphQLClient(context.Background(), Options{BaseURL: tt.baseURL}).(*GraphQLClient)
+		if h.url != tt.want {
+			t.Errorf("GraphQL URL of %q = %v, want %v", tt.baseURL, h.url, tt.want)
+		}
+	}
+}
+
+// TestEnterpriseBaseURL runs the REST client against a stand-in serving
+// the API under /api/v3 only, every request must reach it there
+func TestEnterpriseBaseURL(t *testing.T) {
+	var mutex sync.Mutex
+	var outside []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/orgs/org/repos", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 1, "name": "repo", "full_name": "org/repo"}]`)
+	})
+	mux.HandleFunc("/api/v3/orgs/org/members", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 10, "login": "alice"}]`)
+	})
+	mux.HandleFunc("/api/v3/orgs/org/teams", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 5, "slug": "core", "name": "Core"}]`)
+	})
+	mux.HandleFunc("/api/v3/teams/5/members", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 10, "login": "alice"}]`)
+	})
+	mux.HandleFunc("/api/v3/repos/org/repo/pulls", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"number": 1, "created_at": "2030-01-02T00:00:00Z", "user": {"id": 10, "login": "alice"}}]`)
+	})
+	mux.HandleFunc("/api/v3/repos/org/repo/pulls/1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 100, "number": 1, "created_at": "2030-01-02T00:00:00Z", "user": {"id": 10, "login": "alice"}}`)
+	})
+	mux.HandleFunc("/api/v3/repos/org/repo/pulls/1/reviews", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 1000, "state": "APPROVED", "submitted_at": "2030-01-03T00:00:00Z", "user": {"id": 11, "login": "bob"}}]`)
+	})
+	mux.HandleFunc("/api/v3/repos/org/repo/pulls/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	mux.HandleFunc("/api/v3/repos/org/repo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		outside = append(outside, r.URL.Path)
+		mutex.Unlock()
+		http.NotFound(w, r)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	h := NewGithubClient(context.Background(), Options{BaseURL: srv.URL + "/api/v3/"})
+	agent := &testAgent{bearer: "valid"}
+
+	repos, err := h.GetOrgRepos(agent)
+	if err != nil || len(repos) != 1 {
+		t.Fatalf("GetOrgRepos() = %v, %v", repos, err)
+	}
+
+	if users, err := h.GetOrgMembers(agent); err != nil || len(users) != 1 {
+		t.Fatalf("GetOrgMembers() = %v, %v", users, err)
+	}
+
+	if teams, err := h.GetOrgTeams(agent); err != nil || len(teams) != 1 || len(teams[0].Members) != 1 {
+		t.Fatalf("GetOrgTeams() = %v, %v", teams, err)
+	}
+
+	prs, err := h.GetPullRequests(repos, agent)
+	if err != nil || len(prs) != 1 || len(prs[0].Reviews) != 1 {
+		t.Fatalf("GetPullRequests() = %v, %v", prs, err)
+	}
+
+	if len(outside) > 0 {
+		t.Errorf("requests outside /api/v3: %v", outside)
+	}
+}
@@ -0,0 +1,732 @@
+package gitutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/knishioka/github-pr-stats/models"
+	"github.com/knishioka/github-pr-stats/token"
+	"github.com/knishioka/github-pr-stats/transport"
+)
+
+// Github API Docs: https://docs.github.com/en/graphql/reference/objects#organization
+const orgMembersQuery = `query($org: String!, $cursor: String) {
+  organization(login: $org) {
+    membersWithRole(first: 100, after: $cursor) {
+      nodes { databaseId login }
+      pageInfo { hasNextPage endCursor }
+    }
+  }
+  rateLimit { cost remaining resetAt }
+}`
+
+// orgTeamsQuery gets the teams of an org along with the first page of their members
+const orgTeamsQuery = `query($org: String!, $cursor: String) {
+  organization(login: $org) {
+    teams(first: 50, after: $cursor) {
+      nodes {
+        databaseId slug name
+        members(first: 100) {
+          nodes { databaseId login }
+          pageInfo { hasNextPage endCursor }
+        }
+      }
+      pageInfo { hasNextPage endCursor }
+    }
+  }
+  rateLimit { cost remaining resetAt }
+}`
+
+// teamMembersQuery gets the members which didn't fit in the first page of orgTeamsQuery
+const teamMembersQuery = `query($org: String!, $slug: String!, $cursor: String) {
+  organization(login: $org) {
+    team(slug: $slug) {
+      members(first: 100, after: $cursor) {
+        nodes { databaseId login }
+        pageInfo { hasNextPage endCursor }
+      }
+    }
+  }
+  rateLimit { cost remaining resetAt }
+}`
+
+// gqlRepoFields selects a repo along with what RepoFilter matches
+const gqlRepoFields = `databaseId name nameWithOwner isArchived isFork visibility
+        repositoryTopics(first: 100) { nodes { topic { name } } }`
+
+const orgReposQuery = `query($org: String!, $cursor: String) {
+  organization(login: $org) {
+    repositories(first: 100, after: $cursor) {
+      nodes { ` + gqlRepoFields + ` }
+      pageInfo { hasNextPage endCursor }
+    }
+  }
+  rateLimit { cost remaining resetAt }
+}`
+
+// repoQuery gets a repo listed in the RepoFilter
+const repoQuery = `query($owner: String!, $name: String!) {
+  repository(owner: $owner, name: $name) { ` + gqlRepoFields + ` }
+  rateLimit { cost remaining resetAt }
+}`
+
+// gqlActorFields selects the type, login & user id of an author, bots have ids too
+const gqlActorFields = `__typename login ... on User { databaseId } ... on Bot { databaseId }`
+
+// gqlCommentFields selects an inline review comment or a conversation comment
+const gqlCommentFields = `databaseId createdAt author { ` + gqlActorFields + ` }`
+
+// gqlReviewFields selects a review along with the first page of its inline comments,
+// the queries using it declare $comments which is false with Options.SkipComments
+const gqlReviewFields = `id databaseId state submittedAt author { ` + gqlActorFields + ` }
+          comments(first: 20) @include(if: $comments) {
+            nodes { ` + gqlCommentFields + ` }
+            pageInfo { hasNextPage endCursor }
+          }`
+
+// Github API Docs: https://docs.github.com/en/graphql/reference/objects#pullrequest
+const repoPrsQuery = `query($owner: String!, $name: String!, $order: IssueOrder!, $comments: Boolean!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    pullRequests(first: 50, after: $cursor, orderBy: $order) {
+      nodes {
+        databaseId number additions deletions changedFiles createdAt updatedAt
+        state isDraft merged mergedAt closedAt
+        author { ` + gqlActorFields + ` }
+        mergedBy { ` + gqlActorFields + ` }
+        commits { totalCount }
+        reviews(first: 100) {
+          nodes { ` + gqlReviewFields + ` }
+          pageInfo { hasNextPage endCursor }
+        }
+        comments(first: 50) @include(if: $comments) {
+          nodes { ` + gqlCommentFields + ` }
+          pageInfo { hasNextPage endCursor }
+        }
+      }
+      pageInfo { hasNextPage endCursor }
+    }
+  }
+  rateLimit { cost remaining resetAt }
+}`
+
+// prReviewsQuery gets the reviews which didn't fit in the first page of repoPrsQuery
+const prReviewsQuery = `query($owner: String!, $name: String!, $number: Int!, $comments: Boolean!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    pullRequest(number: $number) {
+      reviews(first: 100, after: $cursor) {
+        nodes { ` + gqlReviewFields + ` }
+        pageInfo { hasNextPage endCursor }
+      }
+    }
+  }
+  rateLimit { cost remaining resetAt }
+}`
+
+// prCommentsQuery gets the conversation comments which didn't fit in the first page of repoPrsQuery
+const prCommentsQuery = `query($owner: String!, $name: String!, $number: Int!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    pullRequest(number: $number) {
+      comments(first: 100, after: $cursor) {
+        nodes { ` + gqlCommentFields + ` }
+        pageInfo { hasNextPage endCursor }
+      }
+    }
+  }
+  rateLimit { cost remaining resetAt }
+}`
+
+// reviewCommentsQuery gets the inline comments which didn't fit in the first page of a review
+const reviewCommentsQuery = `query($id: ID!, $cursor: String) {
+  node(id: $id) {
+    ... on PullRequestReview {
+      comments(first: 100, after: $cursor) {
+        nodes { ` + gqlCommentFields + ` }
+        pageInfo { hasNextPage endCursor }
+      }
+    }
+  }
+  rateLimit { cost remaining resetAt }
+}`
+
+// connection is a page of a GraphQL connection
+type connection[T any] struct {
+	Nodes    []T `json:"nodes"`
+	PageInfo struct {
+		HasNextPage bool   `json:"hasNextPage"`
+		EndCursor   string `json:"endCursor"`
+	} `json:"pageInfo"`
+}
+
+type gqlActor struct {
+	// Typename is the account type, as the REST type: User, Bot, ...
+	Typename   string `json:"__typename"`
+	DatabaseID int64  `json:"databaseId"`
+	Login      string `json:"login"`
+}
+
+type gqlTeam struct {
+	DatabaseID int64                `json:"databaseId"`
+	Slug       string               `json:"slug"`
+	Name       string               `json:"name"`
+	Members    connection[gqlActor] `json:"members"`
+}
+
+type gqlRepo struct {
+	DatabaseID       int64  `json:"databaseId"`
+	Name             string `json:"name"`
+	NameWithOwner    string `json:"nameWithOwner"`
+	IsArchived       bool   `json:"isArchived"`
+	IsFork           bool   `json:"isFork"`
+	Visibility       string `json:"visibility"`
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic struct {
+				Name string `json:"name"`
+			} `json:"topic"`
+		} `json:"nodes"`
+	} `json:"repositoryTopics"`
+}
+
+type gqlComment struct {
+	DatabaseID int64     `json:"databaseId"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Author     *gqlActor `json:"author"`
+}
+
+type gqlReview struct {
+	ID          string                 `json:"id"`
+	DatabaseID  int64                  `json:"databaseId"`
+	State       string                 `json:"state"`
+	SubmittedAt time.Time              `json:"submittedAt"`
+	Author      *gqlActor              `json:"author"`
+	Comments    connection[gqlComment] `json:"comments"`
+}
+
+type gqlPullRequest struct {
+	DatabaseID   int64     `json:"databaseId"`
+	Number       int       `json:"number"`
+	Additions    int       `json:"additions"`
+	Deletions    int       `json:"deletions"`
+	ChangedFiles int       `json:"changedFiles"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+	State        string    `json:"state"`
+	IsDraft      bool      `json:"isDraft"`
+	Merged       bool      `json:"merged"`
+	MergedAt     time.Time `json:"mergedAt"`
+	ClosedAt     time.Time `json:"closedAt"`
+	MergedBy     *gqlActor `json:"mergedBy"`
+	Author       *gqlActor `json:"author"`
+	Commits      struct {
+		TotalCount int `json:"totalCount"`
+	} `json:"commits"`
+	Reviews  connection[gqlReview]  `json:"reviews"`
+	Comments connection[gqlComment] `json:"comments"`
+}
+
+// gqlRateLimit is the rateLimit object requested along with every query
+type gqlRateLimit struct {
+	Cost      int       `json:"cost"`
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"resetAt"`
+}
+
+// queryCost adds up the rate limit cost of the queries made by a GraphQLClient
+type queryCost struct {
+	mutex   sync.Mutex
+	queries int
+	points  int
+	last    gqlRateLimit
+}
+
+// GraphQLClient implements GitHelper over the Github GraphQL v4 API.
+// A single query returns a page of PRs with their sizes, reviews & comments,
+// where GithubClient needs four more REST calls for each PR.
+type GraphQLClient struct {
+	c            *http.Client
+	url          string
+	ctx          context.Context
+	base         time.Time
+	since        time.Time
+	concurrency  int
+	cost         *queryCost
+	repoFilter   *RepoFilter
+	skipComments bool
+}
+
+// NewGraphQLClient returns a GitHelper using the GraphQL API, Options.Cache
+// is ignored as GraphQL queries can't be made conditional
+func NewGraphQLClient(ctx context.Context, opts Options) GitHelper {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+
+	if opts.Client == nil {
+		opts.Client, _ = transport.NewClient(transport.Options{})
+	}
+
+	return &GraphQLClient{
+		c:            opts.Client,
+		url:          graphQLURL(baseURL(opts)),
+		ctx:          ctx,
+		concurrency:  opts.Concurrency,
+		cost:         &queryCost{},
+		repoFilter:   opts.RepoFilter,
+		skipComments: opts.SkipComments,
+	}
+}
+
+// withContext returns a shallow copy of the client whose requests use ctx
+func (h *GraphQLClient) withContext(ctx context.Context) *GraphQLClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
+// graphQLURL returns the GraphQL endpoint of a REST API root,
+// Github Enterprise Server serves it at /api/graphql next to /api/v3
+// Github API Docs: https://docs.github.com/en/enterprise-server/graphql/guides/forming-calls-with-graphql
+func graphQLURL(base string) string {
+	if strings.HasSuffix(base, "/api/v3") {
+		return strings.TrimSuffix(base, "/v3") + "/graphql"
+	}
+
+	return base + "/graphql"
+}
+
+// SetBase sets the base date
+func (h *GraphQLClient) SetBase(base time.Time) {
+	h.base = base
+}
+
+// SetSince lists the PRs by last update down to the ones not updated since
+// the given date instead of by creation down to the base date, unless it is zero
+func (h *GraphQLClient) SetSince(since time.Time) {
+	h.since = since
+}
+
+// GetOrgMembers returns list of accounts that are members of an org
+func (h *GraphQLClient) GetOrgMembers(ita token.InsTokenInterface) (accounts []*models.User, err error) {
+	vars := map[string]interface{}{"org": ita.AccountName()}
+	members, err := queryAll[gqlActor](h, orgMembersQuery, vars, ita, nil, "organization", "membersWithRole")
+	if err != nil {
+		return nil, err
+	}
+
+	for j := 0; j < len(members); j++ {
+		accounts = append(accounts, &models.User{
+			ID:       members[j].DatabaseID,
+			Username: members[j].Login,
+		})
+	}
+
+	return accounts, nil
+}
+
+// GetOrgRepos returns list of repos that belong to org, or the repos listed
+// in the RepoFilter, which pass the RepoFilter
+func (h *GraphQLClient) GetOrgRepos(ita token.InsTokenInterface) (repos []*models.Repo, err error) {
+	var repositories []gqlRepo
+	if h.repoFilter != nil && len(h.repoFilter.Repos) > 0 {
+		for _, name := range h.repoFilter.Repos {
+			vars := map[string]interface{}{"owner": ita.AccountName(), "name": name}
+			data, err := h.query(repoQuery, vars, ita)
+			if err != nil {
+				return nil, err
+			}
+
+			var resp struct {
+				Repository *gqlRepo `json:"repository"`
+			}
+			if err := json.Unmarshal(data, &resp); err != nil || resp.Repository == nil {
+				return nil, fmt.Errorf("graphql: repository %v/%v not found", ita.AccountName(), name)
+			}
+
+			repositories = append(repositories, *resp.Repository)
+		}
+	} else {
+		vars := map[string]interface{}{"org": ita.AccountName()}
+		repositories, err = queryAll[gqlRepo](h, orgReposQuery, vars, ita, nil, "organization", "repositories")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for j := 0; j < len(repositories); j++ {
+		repo := &models.Repo{
+			ID:         repositories[j].DatabaseID,
+			Name:       repositories[j].Name,
+			FullName:   repositories[j].NameWithOwner,
+			Archived:   repositories[j].IsArchived,
+			Fork:       repositories[j].IsFork,
+			Visibility: strings.ToLower(repositories[j].Visibility),
+		}
+
+		for _, node := range repositories[j].RepositoryTopics.Nodes {
+			repo.Topics = append(repo.Topics, node.Topic.Name)
+		}
+
+		if h.repoFilter.Match(repo) {
+			repos = append(repos, repo)
+		}
+	}
+
+	return repos, nil
+}
+
+// GetOrgTeams returns the teams of an org along with their members
+func (h *GraphQLClient) GetOrgTeams(ita token.InsTokenInterface) (teams []*models.Team, err error) {
+	vars := map[string]interface{}{"org": ita.AccountName()}
+	orgTeams, err := queryAll[gqlTeam](h, orgTeamsQuery, vars, ita, nil, "organization", "teams")
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(orgTeams); i++ {
+		members := orgTeams[i].Members.Nodes
+		if orgTeams[i].Members.PageInfo.HasNextPage {
+			vars := map[string]interface{}{
+				"org":    ita.AccountName(),
+				"slug":   orgTeams[i].Slug,
+				"cursor": orgTeams[i].Members.PageInfo.EndCursor,
+			}
+			more, err := queryAll[gqlActor](h, teamMembersQuery, vars, ita, nil, "organization", "team", "members")
+			if err != nil {
+				return nil, err
+			}
+
+			members = append(members, more...)
+		}
+
+		team := &models.Team{
+			ID:   orgTeams[i].DatabaseID,
+			Org:  ita.AccountName(),
+			Slug: orgTeams[i].Slug,
+			Name: orgTeams[i].Name,
+		}
+
+		for j := 0; j < len(members); j++ {
+			team.Members = append(team.Members, &models.User{
+				ID:       members[j].DatabaseID,
+				Username: members[j].Login,
+			})
+		}
+
+		teams = append(teams, team)
+	}
+
+	return teams, nil
+}
+
+// GetPullRequests returns pull reqs along with their reviews & comments for each repo,
+// the repos are queried by a bounded pool of workers
+func (h *GraphQLClient) GetPullRequests(repos []*models.Repo, ita token.InsTokenInterface) (pullReqs []*models.PullRequest, err error) {
+	perRepo := make([][]*models.PullRequest, len(repos))
+	err = forEach(h.ctx, h.concurrency, len(repos), func(ctx context.Context, i int) error {
+		prs, err := h.withContext(ctx).getRepoPullRequests(repos[i], ita)
+		if err != nil {
+			return err
+		}
+
+		perRepo[i] = prs
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(perRepo); i++ {
+		pullReqs = append(pullReqs, perRepo[i]...)
+	}
+
+	h.cost.mutex.Lock()
+	log.Printf("graphql: %v queries cost %v points, %v points left until %v",
+		h.cost.queries, h.cost.points, h.cost.last.Remaining, h.cost.last.ResetAt.Local().Format(time.Kitchen))
+	h.cost.mutex.Unlock()
+
+	return pullReqs, nil
+}
+
+func (h *GraphQLClient) getRepoPullRequests(repo *models.Repo, ita token.InsTokenInterface) (pullReqs []*models.PullRequest, err error) {
+	vars := map[string]interface{}{
+		"owner":    ita.AccountName(),
+		"name":     repo.Name,
+		"order":    map[string]string{"field": "CREATED_AT", "direction": "DESC"},
+		"comments": !h.skipComments,
+	}
+	if !h.since.IsZero() {
+		vars["order"] = map[string]string{"field": "UPDATED_AT", "direction": "DESC"}
+	}
+
+	prs, err := queryAll(h, repoPrsQuery, vars, ita, func(prs []gqlPullRequest) bool {
+		if !h.since.IsZero() {
+			return !h.since.Before(prs[len(prs)-1].UpdatedAt)
+		}
+
+		return !h.base.Before(prs[len(prs)-1].CreatedAt)
+	}, "repository", "pullRequests")
+	if err != nil {
+		return nil, err
+	}
+
+	for j := 0; j < len(prs); j++ {
+		pr := &models.PullRequest{
+			ID:           prs[j].DatabaseID,
+			Org:          ita.AccountName(),
+			RepoID:       repo.ID,
+			RepoName:     repo.Name,
+			PrNo:         prs[j].Number,
+			Additions:    prs[j].Additions,
+			Deletions:    prs[j].Deletions,
+			ChangedFiles: prs[j].ChangedFiles,
+			CreatedAt:    prs[j].CreatedAt,
+			UpdatedAt:    prs[j].UpdatedAt,
+			State:        restState(prs[j].State),
+			Draft:        prs[j].IsDraft,
+			Merged:       prs[j].Merged,
+			MergedAt:     prs[j].MergedAt,
+			ClosedAt:     prs[j].ClosedAt,
+			Commits:      prs[j].Commits.TotalCount,
+			Reviews:      []*models.Review{},
+			Comments:     []*models.Comment{},
+		}
+
+		if prs[j].Author != nil {
+			pr.UserID = prs[j].Author.DatabaseID
+			pr.Username = prs[j].Author.Login
+			pr.UserType = prs[j].Author.Typename
+		}
+
+		if prs[j].MergedBy != nil {
+			pr.MergedByID = prs[j].MergedBy.DatabaseID
+			pr.MergedBy = prs[j].MergedBy.Login
+			pr.MergedByType = prs[j].MergedBy.Typename
+		}
+
+		revs := prs[j].Reviews.Nodes
+		if prs[j].Reviews.PageInfo.HasNextPage {
+			vars := map[string]interface{}{
+				"owner":    ita.AccountName(),
+				"name":     repo.Name,
+				"number":   pr.PrNo,
+				"comments": !h.skipComments,
+				"cursor":   prs[j].Reviews.PageInfo.EndCursor,
+			}
+			more, err := queryAll[gqlReview](h, prReviewsQuery, vars, ita, nil, "repository", "pullRequest", "reviews")
+			if err != nil {
+				return nil, err
+			}
+
+			revs = append(revs, more...)
+		}
+
+		for k := 0; k < len(revs); k++ {
+			review := &models.Review{
+				ID:          revs[k].DatabaseID,
+				State:       revs[k].State,
+				SubmittedAt: revs[k].SubmittedAt,
+			}
+
+			if revs[k].Author != nil {
+				review.UserID = revs[k].Author.DatabaseID
+				review.Username = revs[k].Author.Login
+				review.UserType = revs[k].Author.Typename
+			}
+
+			pr.Reviews = append(pr.Reviews, review)
+
+			comments := revs[k].Comments.Nodes
+			if revs[k].Comments.PageInfo.HasNextPage {
+				vars := map[string]interface{}{
+					"id":     revs[k].ID,
+					"cursor": revs[k].Comments.PageInfo.EndCursor,
+				}
+				more, err := queryAll[gqlComment](h, reviewCommentsQuery, vars, ita, nil, "node", "comments")
+				if err != nil {
+					return nil, err
+				}
+
+				comments = append(comments, more...)
+			}
+
+			for l := 0; l < len(comments); l++ {
+				pr.Comments = append(pr.Comments, newComment(models.CommentReview, comments[l]))
+			}
+		}
+
+		comments := prs[j].Comments.Nodes
+		if prs[j].Comments.PageInfo.HasNextPage {
+			vars := map[string]interface{}{
+				"owner":  ita.AccountName(),
+				"name":   repo.Name,
+				"number": pr.PrNo,
+				"cursor": prs[j].Comments.PageInfo.EndCursor,
+			}
+			more, err := queryAll[gqlComment](h, prCommentsQuery, vars, ita, nil, "repository", "pullRequest", "comments")
+			if err != nil {
+				return nil, err
+			}
+
+			comments = append(comments, more...)
+		}
+
+		for k := 0; k < len(comments); k++ {
+			pr.Comments = append(pr.Comments, newComment(models.CommentIssue, comments[k]))
+		}
+
+		pullReqs = append(pullReqs, pr)
+	}
+
+	return pullReqs, nil
+}
+
+// newComment returns the model of a GraphQL comment of the given kind
+func newComment(kind string, c gqlComment) *models.Comment {
+	comment := &models.Comment{
+		ID:        c.DatabaseID,
+		Kind:      kind,
+		CreatedAt: c.CreatedAt,
+	}
+
+	if c.Author != nil {
+		comment.UserID = c.Author.DatabaseID
+		comment.Username = c.Author.Login
+		comment.UserType = c.Author.Typename
+	}
+
+	return comment
+}
+
+// restState maps a GraphQL PullRequestState to the REST state: open or closed
+func restState(state string) string {
+	if state == "OPEN" {
+		return "open"
+	}
+
+	return "closed"
+}
+
+// queryAll follows the cursor of the connection found at path in the query
+// data and returns the nodes of every page. The query must take a $cursor
+// variable, a cursor already set in vars is the starting point.
+// If done is set it is called with each non-empty page and paging stops
+// after the first page it returns true for.
+func queryAll[T any](h *GraphQLClient, query string, vars map[string]interface{}, ita token.InsTokenInterface, done func(page []T) bool, path ...string) (nodes []T, err error) {
+	for {
+		data, err := h.query(query, vars, ita)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range path {
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+				return nil, fmt.Errorf("graphql: %v not found in response", strings.Join(path, "."))
+			}
+
+			data = fields[key]
+		}
+
+		var page connection[T]
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, fmt.Errorf("graphql: %v unmarshal error: %v", strings.Join(path, "."), err)
+		}
+
+		nodes = append(nodes, page.Nodes...)
+		if !page.PageInfo.HasNextPage || len(page.Nodes) == 0 || (done != nil && done(page.Nodes)) {
+			break
+		}
+
+		vars["cursor"] = page.PageInfo.EndCursor
+	}
+
+	return nodes, nil
+}
+
+// query posts a GraphQL query and returns its data, the cost of the
+// query is added to the client's total
+func (h *GraphQLClient) query(query string, vars map[string]interface{}, ita token.InsTokenInterface) (json.RawMessage, error) {
+	payload, err := json.Marshal(map[string]interface{}{"query": query, "variables": vars})
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := h.post(payload, ita)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("graphql: response unmarshal error: %v", err)
+	}
+
+	if len(resp.Errors) > 0 {
+		return nil, fmt.Errorf("graphql: query error: %v", resp.Errors[0].Message)
+	}
+
+	var rate struct {
+		RateLimit *gqlRateLimit `json:"rateLimit"`
+	}
+	if err := json.Unmarshal(resp.Data, &rate); err == nil && rate.RateLimit != nil {
+		h.cost.mutex.Lock()
+		h.cost.queries++
+		h.cost.points += rate.RateLimit.Cost
+		h.cost.last = *rate.RateLimit
+		h.cost.mutex.Unlock()
+	}
+
+	return resp.Data, nil
+}
+
+// post sends payload to the GraphQL endpoint, an expired token is renewed once
+func (h *GraphQLClient) post(payload []byte, ita token.InsTokenInterface) (body []byte, err error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(h.ctx, "POST", h.url, bytes.NewReader(payload))
+		if err != nil {
+			return body, fmt.Errorf("create new HTTP request: %v", err.Error())
+		}
+
+		bearer := ita.Bearer()
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %v", bearer))
+		req.Header.Add("Content-Type", "application/json")
+
+		data, err := h.c.Do(req)
+		if err != nil {
+			return body, fmt.Errorf("make request error: %v", err.Error())
+		}
+
+		body, err = ioutil.ReadAll(data.Body)
+		data.Body.Close()
+		if err != nil {
+			return body, fmt.Errorf("read body error: %v", err.Error())
+		}
+
+		if data.StatusCode == 401 && attempt == 0 {
+			if err := ita.Renew(bearer); err != nil {
+				return body, fmt.Errorf("get installation token: %v", err.Error())
+			}
+
+			continue
+		}
+
+		if data.StatusCode != 200 {
+			return body, fmt.Errorf("graphql: unexpected response status %v", data.StatusCode)
+		}
+
+		return body, nil
+	}
+}
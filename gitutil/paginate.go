@@ -0,0 +1,33 @@
+package gitutil
+
+import (
+	"encoding/json"
+
+	"github.com/knishioka/github-pr-stats/token"
+)
+
+// paginate follows the Link rel="next" headers starting at uri and returns the
+// items of every page. If done is set it is called with each non-empty page
+// and paging stops after the first page it returns true for.
+func paginate[T any](h *GithubClient, uri string, ita token.InsTokenInterface, done func(page []T) bool) (items []T, err error) {
+	for uri != "" {
+		body, next, err := h.getPage(uri, ita)
+		if err != nil {
+			return nil, err
+		}
+
+		var page []T
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, err
+		}
+
+		items = append(items, page...)
+		if len(page) == 0 || (done != nil && done(page)) {
+			break
+		}
+
+		uri = next
+	}
+
+	return items, nil
+}
@@ -0,0 +1,89 @@
+package gitutil
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForEach(t *testing.T) {
+	errFail := errors.New("fail")
+	tests := []struct {
+		name    string
+		workers int
+		n       int
+		failAt  int
+		wantErr error
+	}{
+		{name: "all succeed", workers: 4, n: 50, failAt: -1},
+		{name: "more workers than calls", workers: 8, n: 3, failAt: -1},
+		{name: "no workers", workers: 0, n: 5, failAt: -1},
+		{name: "no calls", workers: 4, n: 0, failAt: -1},
+		{name: "first call fails", workers: 4, n: 50, failAt: 0, wantErr: errFail},
+		{name: "middle call fails", workers: 4, n: 50, failAt: 10, wantErr: errFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := make([]int, tt.n)
+			var cancelled int32
+			inFlight := make(chan int, tt.n)
+			err := forEach(context.Background(), tt.workers, tt.n, func(ctx context.Context, i int) error {
+				if i == tt.failAt {
+					// fail once the other workers are busy with the next calls
+					for j := 1; j < tt.workers; j++ {
+						<-inFlight
+					}
+					return errFail
+				}
+
+				if tt.failAt >= 0 && i > tt.failAt {
+					// in-flight calls must see the cancellation of the failure
+					inFlight <- i
+					select {
+					case <-ctx.Done():
+						atomic.AddInt32(&cancelled, 1)
+						return ctx.Err()
+					case <-time.After(5 * time.Second):
+						t.Errorf("call %v not cancelled", i)
+						return nil
+					}
+				}
+
+				results[i] = i * i
+				return nil
+			})
+
+			if err != tt.wantErr {
+				t.Fatalf("forEach() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				if got := atomic.LoadInt32(&cancelled); got < int32(tt.workers-1) {
+					t.Errorf("%v in-flight calls saw ctx.Done(), want at least %v", got, tt.workers-1)
+				}
+				return
+			}
+
+			for i, got := range results {
+				if got != i*i {
+					t.Errorf("results[%v] = %v, want %v", i, got, i*i)
+				}
+			}
+		})
+	}
+}
+
+func TestForEachParentCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := forEach(ctx, 2, 10, func(ctx context.Context, i int) error {
+		return nil
+	})
+	if err != context.Canceled {
+		t.Fatalf("forEach() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -1,6 +1,7 @@
 package gitutil
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -9,64 +10,150 @@ import (
 	"github.com/knishioka/github-pr-stats/token"
 )
 
-// GetPullRequests calls github API and returns pul reqs for each repo
+// prJob is a listed PR waiting for its detail, reviews & comments to be fetched
+type prJob struct {
+	repo *models.Repo
+	pr   *github.PullRequest
+}
+
+// GetPullRequests calls github API and returns pul reqs for each repo.
+// Repos are listed and PRs are fetched by a bounded pool of workers, the
+// returned PRs keep the order of the repos and of the PR listings.
 func (h *GithubClient) GetPullRequests(repos []*models.Repo, ita token.InsTokenInterface) (pullReqs []*models.PullRequest, err error) {
-	for i := 0; i < len(repos); i++ {
-		// Get all pull requests for the repo
-		prs, err := h.GetAllPullRequests(h.getRepoPrsURL(ita.AccountName(), repos[i].Name), ita)
+	// Get all pull requests of every repo
+	listed := make([][]*github.PullRequest, len(repos))
+	err = forEach(h.ctx, h.concurrency, len(repos), func(ctx context.Context, i int) error {
+		hc := h.withContext(ctx)
+		prs, err := hc.GetAllPullRequests(hc.getRepoPrsURL(ita.AccountName(), repos[i].Name), ita)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		// for each PR, get all of its reviews
-		for j := 0; j < len(prs); j++ {
-			detailData, err := h.Get(h.getPrDetailURL(ita.AccountName(), repos[i].Name, prs[j].GetNumber()), ita)
-			if err != nil {
-				return nil, err
-			}
-
-			pullReqDetail := &github.PullRequest{}
-			if err := json.Unmarshal(detailData, &pullReqDetail); err != nil {
-				return nil, fmt.Errorf("pull request detail unmarshal error: %v ", err)
-			}
-
-			pr := &models.PullRequest{
-				ID:           prs[j].GetID(),
-				RepoID:       repos[i].ID,
-				RepoName:     repos[i].Name,
-				UserID:       prs[j].User.GetID(),
-				Username:     prs[j].User.GetLogin(),
-				PrNo:         prs[j].GetNumber(),
-				Additions:    pullReqDetail.GetAdditions(),
-				Deletions:    pullReqDetail.GetDeletions(),
-				ChangedFiles: pullReqDetail.GetChangedFiles(),
-				CreatedAt:    pullReqDetail.GetCreatedAt(),
-				UpdatedAt:    pullReqDetail.GetUpdatedAt(),
-				Commits:      pullReqDetail.GetCommits(),
-				Reviews:      []*models.Review{},
-			}
-
-			// get all reviews of the PR
-			revs, err := h.GetAllReviews(h.getPrReviewsURL(ita.AccountName(), repos[i].Name, pr.PrNo), ita)
-			if err != nil {
-				return nil, err
-			}
-
-			// get the needed values and store
-			for k := 0; k < len(revs); k++ {
-				pr.Reviews = append(pr.Reviews, &models.Review{
-					ID:          revs[k].GetID(),
-					State:       revs[k].GetState(),
-					SubmittedAt: revs[k].GetSubmittedAt(),
-					UserID:      revs[k].User.GetID(),
-					Username:    revs[k].User.GetLogin(),
-				})
-			}
-
-			// append PR to the final list to be returned
-			pullReqs = append(pullReqs, pr)
+		listed[i] = prs
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []prJob
+	for i := 0; i < len(repos); i++ {
+		for j := 0; j < len(listed[i]); j++ {
+			jobs = append(jobs, prJob{repo: repos[i], pr: listed[i][j]})
 		}
 	}
 
+	// for each PR, get its detail, all of its reviews & comments
+	pullReqs = make([]*models.PullRequest, len(jobs))
+	err = forEach(h.ctx, h.concurrency, len(jobs), func(ctx context.Context, i int) error {
+		pr, err := h.withContext(ctx).getPullRequest(jobs[i], ita)
+		if err != nil {
+			return err
+		}
+
+		pullReqs[i] = pr
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	return pullReqs, nil
 }
+
+// getPullRequest fetches the detail, the reviews and unless skipped the comments of a listed PR
+func (h *GithubClient) getPullRequest(job prJob, ita token.InsTokenInterface) (*models.PullRequest, error) {
+	detailData, err := h.Get(h.getPrDetailURL(ita.AccountName(), job.repo.Name, job.pr.GetNumber()), ita)
+	if err != nil {
+		return nil, err
+	}
+
+	pullReqDetail := &pullRequestDetail{}
+	if err := json.Unmarshal(detailData, &pullReqDetail); err != nil {
+		return nil, fmt.Errorf("pull request detail unmarshal error: %v ", err)
+	}
+
+	pr := &models.PullRequest{
+		ID:           job.pr.GetID(),
+		Org:          ita.AccountName(),
+		RepoID:       job.repo.ID,
+		RepoName:     job.repo.Name,
+		UserID:       job.pr.User.GetID(),
+		Username:     job.pr.User.GetLogin(),
+		UserType:     job.pr.User.GetType(),
+		PrNo:         job.pr.GetNumber(),
+		Additions:    pullReqDetail.GetAdditions(),
+		Deletions:    pullReqDetail.GetDeletions(),
+		ChangedFiles: pullReqDetail.GetChangedFiles(),
+		CreatedAt:    pullReqDetail.GetCreatedAt(),
+		UpdatedAt:    pullReqDetail.GetUpdatedAt(),
+		State:        pullReqDetail.GetState(),
+		Draft:        pullReqDetail.Draft,
+		Merged:       pullReqDetail.GetMerged(),
+		MergedAt:     pullReqDetail.GetMergedAt(),
+		ClosedAt:     pullReqDetail.GetClosedAt(),
+		MergedByID:   pullReqDetail.MergedBy.GetID(),
+		MergedBy:     pullReqDetail.MergedBy.GetLogin(),
+		MergedByType: pullReqDetail.MergedBy.GetType(),
+		Commits:      pullReqDetail.GetCommits(),
+		Reviews:      []*models.Review{},
+		Comments:     []*models.Comment{},
+	}
+
+	// get all reviews of the PR
+	revs, err := h.GetAllReviews(h.getPrReviewsURL(ita.AccountName(), job.repo.Name, pr.PrNo), ita)
+	if err != nil {
+		return nil, err
+	}
+
+	// get the needed values and store
+	for k := 0; k < len(revs); k++ {
+		pr.Reviews = append(pr.Reviews, &models.Review{
+			ID:          revs[k].GetID(),
+			State:       revs[k].GetState(),
+			SubmittedAt: revs[k].GetSubmittedAt(),
+			UserID:      revs[k].User.GetID(),
+			Username:    revs[k].User.GetLogin(),
+			UserType:    revs[k].User.GetType(),
+		})
+	}
+
+	if h.skipComments {
+		return pr, nil
+	}
+
+	// get all inline & conversation comments of the PR
+	reviewComments, err := h.GetAllReviewComments(h.getPrReviewCommentsURL(ita.AccountName(), job.repo.Name, pr.PrNo), ita)
+	if err != nil {
+		return nil, err
+	}
+
+	for k := 0; k < len(reviewComments); k++ {
+		pr.Comments = append(pr.Comments, &models.Comment{
+			ID:        reviewComments[k].GetID(),
+			Kind:      models.CommentReview,
+			CreatedAt: reviewComments[k].GetCreatedAt(),
+			UserID:    reviewComments[k].User.GetID(),
+			Username:  reviewComments[k].User.GetLogin(),
+			UserType:  reviewComments[k].User.GetType(),
+		})
+	}
+
+	issueComments, err := h.GetAllIssueComments(h.getPrIssueCommentsURL(ita.AccountName(), job.repo.Name, pr.PrNo), ita)
+	if err != nil {
+		return nil, err
+	}
+
+	for k := 0; k < len(issueComments); k++ {
+		pr.Comments = append(pr.Comments, &models.Comment{
+			ID:        issueComments[k].GetID(),
+			Kind:      models.CommentIssue,
+			CreatedAt: issueComments[k].GetCreatedAt(),
+			UserID:    issueComments[k].User.GetID(),
+			Username:  issueComments[k].User.GetLogin(),
+			UserType:  issueComments[k].User.GetType(),
+		})
+	}
+
+	return pr, nil
+}
@@ -0,0 +1,84 @@
+package gitutil
+
+import (
+	"path"
+	"strings"
+
+	"github.com/knishioka/github-pr-stats/models"
+)
+
+// RepoFilter selects the repos of an org to collect the PRs of,
+// names, topics & visibilities are matched case insensitively
+type RepoFilter struct {
+	// Repos are the names of the repos to get instead of listing every
+	// repo of the org, the other rules still apply to them
+	Repos []string
+	// Include & Exclude are name patterns, as of path.Match, a repo
+	// must match one of Include, if any, and none of Exclude
+	Include []string
+	Exclude []string
+	// SkipArchived & SkipForks leave out the archived & the forked repos
+	SkipArchived bool
+	SkipForks    bool
+	// Topics keeps the repos having at least one of the topics, if any
+	Topics []string
+	// Visibilities keeps the public, private or internal repos, if any
+	Visibilities []string
+}
+
+// Match tells whether repo passes the filter, a nil filter passes every repo
+func (f *RepoFilter) Match(repo *models.Repo) bool {
+	if f == nil {
+		return true
+	}
+
+	if (f.SkipArchived && repo.Archived) || (f.SkipForks && repo.Fork) {
+		return false
+	}
+
+	if len(f.Include) > 0 && !matchAny(f.Include, repo.Name) {
+		return false
+	}
+
+	if matchAny(f.Exclude, repo.Name) {
+		return false
+	}
+
+	if len(f.Visibilities) > 0 && !containsFold(f.Visibilities, repo.Visibility) {
+		return false
+	}
+
+	if len(f.Topics) == 0 {
+		return true
+	}
+
+	for _, topic := range repo.Topics {
+		if containsFold(f.Topics, topic) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchAny tells whether name matches any of the patterns
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name)); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsFold tells whether list contains s, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+
+	return false
+}
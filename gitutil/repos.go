@@ -1,25 +1,60 @@
 package gitutil
 
 import (
+	"encoding/json"
+	"fmt"
+
 	"github.com/knishioka/github-pr-stats/models"
 	"github.com/knishioka/github-pr-stats/token"
 )
 
-// GetOrgRepos calls github API and returns list of repos that belong to org
+// GetOrgRepos calls github API and returns list of repos that belong to org,
+// or the repos listed in the RepoFilter, which pass the RepoFilter
 func (h *GithubClient) GetOrgRepos(ita token.InsTokenInterface) (repos []*models.Repo, err error) {
-	// Get org. memebrs
-	repositories, err := h.GetAllRepos(h.getOrgReposURL(ita.AccountName()), ita)
-	if err != nil {
-		return nil, err
+	var repositories []*repositoryDetail
+	if h.repoFilter != nil && len(h.repoFilter.Repos) > 0 {
+		for _, name := range h.repoFilter.Repos {
+			data, err := h.Get(h.getRepoURL(ita.AccountName(), name), ita)
+			if err != nil {
+				return nil, err
+			}
+
+			repository := &repositoryDetail{}
+			if err := json.Unmarshal(data, repository); err != nil {
+				return nil, fmt.Errorf("repo unmarshal error: %v ", err)
+			}
+
+			repositories = append(repositories, repository)
+		}
+	} else {
+		repositories, err = paginate[*repositoryDetail](h, h.getOrgReposURL(ita.AccountName()), ita, nil)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	for j := 0; j < len(repositories); j++ {
 		repo := &models.Repo{
-			ID:   repositories[j].GetID(),
-			Name: repositories[j].GetName(),
+			ID:         repositories[j].GetID(),
+			Name:       repositories[j].GetName(),
+			FullName:   repositories[j].GetFullName(),
+			Archived:   repositories[j].GetArchived(),
+			Fork:       repositories[j].GetFork(),
+			Topics:     repositories[j].Topics,
+			Visibility: repositories[j].Visibility,
+		}
+
+		// older servers only tell whether the repo is private
+		if repo.Visibility == "" {
+			repo.Visibility = "public"
+			if repositories[j].GetPrivate() {
+				repo.Visibility = "private"
+			}
 		}
 
-		repos = append(repos, repo)
+		if h.repoFilter.Match(repo) {
+			repos = append(repos, repo)
+		}
 	}
 
 	return repos, nil
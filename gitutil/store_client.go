@@ -0,0 +1,257 @@
+package gitutil
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/knishioka/github-pr-stats/models"
+	"github.com/knishioka/github-pr-stats/store"
+	"github.com/knishioka/github-pr-stats/token"
+)
+
+// syncMargin is subtracted from the last sync of a repo when fetching the
+// PRs updated since, to absorb the skew between the local & the github clocks
+const syncMargin = 10 * time.Minute
+
+// StoreClient implements GitHelper on top of a store.Store. Online it saves
+// what its getter gets from github into the store, fetching only the PRs of a
+// repo updated since its last sync once the store holds its PRs back to the
+// base date, and returns the PRs of the store. Offline it reads everything from
+// the store and makes no API calls. Every repo of the org is stored, the
+// RepoFilter selects the repos when they are returned.
+type StoreClient struct {
+	getter     GitHelper
+	store      *store.Store
+	repoFilter *RepoFilter
+	base       time.Time
+}
+
+// NewStoreClient returns a GitHelper saving what getter gets into st, getter
+// must list every repo of the org, the stored repos are selected by filter
+func NewStoreClient(getter GitHelper, st *store.Store, filter *RepoFilter) GitHelper {
+	return &StoreClient{getter: getter, store: st, repoFilter: filter}
+}
+
+// NewOfflineClient returns a GitHelper reading from st only,
+// the stored repos are selected by filter
+func NewOfflineClient(st *store.Store, filter *RepoFilter) GitHelper {
+	return &StoreClient{store: st, repoFilter: filter}
+}
+
+// SetBase sets the base date
+func (c *StoreClient) SetBase(base time.Time) {
+	c.base = base
+	if c.getter != nil {
+		c.getter.SetBase(base)
+	}
+}
+
+// SetSince is set by GetPullRequests from the last sync of each repo
+func (c *StoreClient) SetSince(since time.Time) {}
+
+// GetOrgMembers returns the members of the org and saves them
+func (c *StoreClient) GetOrgMembers(ita token.InsTokenInterface) ([]*models.User, error) {
+	if c.getter == nil {
+		return c.store.Users(ita.AccountName())
+	}
+
+	users, err := c.getter.GetOrgMembers(ita)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.store.PutUsers(ita.AccountName(), users); err != nil {
+		return nil, fmt.Errorf("error storing members: %v", err.Error())
+	}
+
+	return users, nil
+}
+
+// GetOrgRepos saves every repo of the org and returns the selected ones
+func (c *StoreClient) GetOrgRepos(ita token.InsTokenInterface) ([]*models.Repo, error) {
+	if c.getter == nil {
+		repos, err := c.store.Repos(ita.AccountName())
+		if err != nil {
+			return nil, err
+		}
+
+		return c.selected(repos), nil
+	}
+
+	repos, err := c.getter.GetOrgRepos(ita)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.store.PutRepos(ita.AccountName(), repos); err != nil {
+		return nil, fmt.Errorf("error storing repos: %v", err.Error())
+	}
+
+	return c.selected(repos), nil
+}
+
+// GetOrgTeams returns the teams of the org and saves them
+func (c *StoreClient) GetOrgTeams(ita token.InsTokenInterface) ([]*models.Team, error) {
+	if c.getter == nil {
+		return c.store.Teams(ita.AccountName())
+	}
+
+	teams, err := c.getter.GetOrgTeams(ita)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.store.PutTeams(ita.AccountName(), teams); err != nil {
+		return nil, fmt.Errorf("error storing teams: %v", err.Error())
+	}
+
+	return teams, nil
+}
+
+// GetPullRequests saves the PRs of repos fetched since the last sync and
+// returns the stored PRs of repos created after the base date, in the order
+// of repos & newest first like the github listings
+func (c *StoreClient) GetPullRequests(repos []*models.Repo, ita token.InsTokenInterface) ([]*models.PullRequest, error) {
+	org := ita.AccountName()
+	if c.getter != nil {
+		if err := c.sync(repos, ita); err != nil {
+			return nil, err
+		}
+	} else if err := c.checkSynced(repos, org); err != nil {
+		return nil, err
+	}
+
+	stored, err := c.store.PullRequests(org)
+	if err != nil {
+		return nil, err
+	}
+
+	perRepo := make(map[int64][]*models.PullRequest)
+	for _, pr := range stored {
+		if c.base.Before(pr.CreatedAt) {
+			perRepo[pr.RepoID] = append(perRepo[pr.RepoID], pr)
+		}
+	}
+
+	var pullReqs []*models.PullRequest
+	for _, repo := range repos {
+		prs := perRepo[repo.ID]
+		sort.SliceStable(prs, func(i, j int) bool {
+			return prs[j].CreatedAt.Before(prs[i].CreatedAt)
+		})
+
+		pullReqs = append(pullReqs, prs...)
+	}
+
+	return pullReqs, nil
+}
+
+// sync fetches the PRs of repos into the store: for each repo the PRs updated
+// since its last sync when the store holds its PRs back to the base date, else
+// the PRs created after the base date. The repos sharing a last sync are
+// fetched together.
+func (c *StoreClient) sync(repos []*models.Repo, ita token.InsTokenInterface) error {
+	type group struct {
+		since time.Time
+		repos []*models.Repo
+	}
+
+	// the repos synced together share their next sync
+	started := time.Now()
+	org := ita.AccountName()
+	froms := make(map[int64]time.Time)
+	var groups []*group
+	for _, repo := range repos {
+		from, to, err := c.store.Synced(org, repo.ID)
+		if err != nil {
+			return err
+		}
+
+		since := time.Time{}
+		if !to.IsZero() && !c.base.Before(from) {
+			since = to.Add(-syncMargin)
+		} else {
+			from = c.base
+		}
+
+		froms[repo.ID] = from
+		i := 0
+		for i < len(groups) && !groups[i].since.Equal(since) {
+			i++
+		}
+
+		if i == len(groups) {
+			groups = append(groups, &group{since: since})
+		}
+		groups[i].repos = append(groups[i].repos, repo)
+	}
+
+	defer c.getter.SetSince(time.Time{})
+	for _, g := range groups {
+		if !g.since.IsZero() {
+			log.Printf("getting %v pull requests of %v repos updated since %v", org, len(g.repos), g.since.Format(time.RFC3339))
+		}
+
+		c.getter.SetSince(g.since)
+		prs, err := c.getter.GetPullRequests(g.repos, ita)
+		if err != nil {
+			return err
+		}
+
+		if err := c.store.PutPullRequests(org, prs); err != nil {
+			return fmt.Errorf("error storing pull requests: %v", err.Error())
+		}
+
+		for _, repo := range g.repos {
+			if err := c.store.SetSynced(org, repo.ID, froms[repo.ID], started); err != nil {
+				return fmt.Errorf("error storing sync dates: %v", err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkSynced warns about the repos whose PRs aren't stored back to the base
+// date, offline their stats would silently miss the PRs created before
+func (c *StoreClient) checkSynced(repos []*models.Repo, org string) error {
+	for _, repo := range repos {
+		from, _, err := c.store.Synced(org, repo.ID)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case from.IsZero():
+			log.Printf("warning: %v/%v was never synced, it has no stored pull requests", org, repo.Name)
+		case c.base.Before(from):
+			log.Printf("warning: %v/%v pull requests are only stored from %v, after the base date %v",
+				org, repo.Name, from.Format(time.RFC3339), c.base.Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
+
+// selected returns the repos which pass the RepoFilter
+func (c *StoreClient) selected(repos []*models.Repo) []*models.Repo {
+	var selected []*models.Repo
+	for _, repo := range repos {
+		if c.listed(repo) && c.repoFilter.Match(repo) {
+			selected = append(selected, repo)
+		}
+	}
+
+	return selected
+}
+
+// listed tells whether repo is one of the repos of the RepoFilter, if any
+func (c *StoreClient) listed(repo *models.Repo) bool {
+	if c.repoFilter == nil || len(c.repoFilter.Repos) == 0 {
+		return true
+	}
+
+	return containsFold(c.repoFilter.Repos, repo.Name)
+}
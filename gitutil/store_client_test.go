@@ -0,0 +1,163 @@
+package gitutil
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knishioka/github-pr-stats/models"
+	"github.com/knishioka/github-pr-stats/store"
+	"github.com/knishioka/github-pr-stats/token"
+)
+
+// fakeGetter returns its repos & their PRs and records each PR fetch
+type fakeGetter struct {
+	repos []*models.Repo
+	prs   map[string][]*models.PullRequest
+	since time.Time
+	calls []string
+}
+
+func (g *fakeGetter) GetOrgRepos(token.InsTokenInterface) ([]*models.Repo, error) {
+	return g.repos, nil
+}
+func (g *fakeGetter) GetOrgMembers(token.InsTokenInterface) ([]*models.User, error) {
+	return nil, nil
+}
+func (g *fakeGetter) GetOrgTeams(token.InsTokenInterface) ([]*models.Team, error) { return nil, nil }
+func (g *fakeGetter) SetBase(time.Time)                                           {}
+func (g *fakeGetter) SetSince(since time.Time)                                    { g.since = since }
+
+// GetPullRequests records "<repos> full" or "<repos> since" calls
+func (g *fakeGetter) GetPullRequests(repos []*models.Repo, ita token.InsTokenInterface) (prs []*models.PullRequest, err error) {
+	var names []string
+	for _, repo := range repos {
+		names = append(names, repo.Name)
+		prs = append(prs, g.prs[repo.Name]...)
+	}
+
+	mode := "full"
+	if !g.since.IsZero() {
+		mode = "since"
+	}
+	g.calls = append(g.calls, strings.Join(names, ",")+" "+mode)
+
+	return prs, nil
+}
+
+func TestStoreClientSync(t *testing.T) {
+	now := time.Now()
+	base := now.AddDate(0, 0, -30)
+	a, b := &models.Repo{ID: 1, Name: "a"}, &models.Repo{ID: 2, Name: "b"}
+	pr := func(repo *models.Repo, no int, age time.Duration) *models.PullRequest {
+		return &models.PullRequest{Org: "org", RepoID: repo.ID, RepoName: repo.Name, PrNo: no, CreatedAt: now.Add(-age)}
+	}
+
+	getter := &fakeGetter{repos: []*models.Repo{a, b}, prs: map[string][]*models.PullRequest{
+		"a": {pr(a, 2, time.Hour), pr(a, 1, 48*time.Hour)},
+		"b": {pr(b, 1, 20*24*time.Hour)},
+	}}
+
+	st, err := store.Open(filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	c := NewStoreClient(getter, st, &RepoFilter{Exclude: []string{"b"}})
+	c.SetBase(base)
+	ita := token.NewStaticTokenAgent("token", "org")
+
+	// every repo is stored, the filter applies to the returned ones
+	repos, err := c.GetOrgRepos(ita)
+	if err != nil || len(repos) != 1 || repos[0].Name != "a" {
+		t.Fatalf("GetOrgRepos() = %v, %v", repos, err)
+	}
+	if stored, err := st.Repos("org"); err != nil || len(stored) != 2 {
+		t.Fatalf("stored repos = %v, %v, want a & b", stored, err)
+	}
+
+	runs := []struct {
+		name      string
+		base      time.Time
+		repos     []*models.Repo
+		wantCalls []string
+		wantPrs   string
+	}{
+		{name: "first sync", base: base, repos: []*models.Repo{a}, wantCalls: []string{"a full"}, wantPrs: "a#2 a#1"},
+		{name: "incremental", base: base, repos: []*models.Repo{a}, wantCalls: []string{"a since"}, wantPrs: "a#2 a#1"},
+		{name: "new repo in the selection", base: base, repos: []*models.Repo{a, b}, wantCalls: []string{"a since", "b full"}, wantPrs: "a#2 a#1 b#1"},
+		{name: "both synced", base: base, repos: []*models.Repo{a, b}, wantCalls: []string{"a,b since"}, wantPrs: "a#2 a#1 b#1"},
+		{name: "earlier base", base: base.AddDate(0, 0, -30), repos: []*models.Repo{a, b}, wantCalls: []string{"a,b full"}, wantPrs: "a#2 a#1 b#1"},
+		{name: "later base", base: now.AddDate(0, 0, -1), repos: []*models.Repo{a, b}, wantCalls: []string{"a,b since"}, wantPrs: "a#2"},
+	}
+
+	for _, run := range runs {
+		getter.calls = nil
+		c.SetBase(run.base)
+		prs, err := c.GetPullRequests(run.repos, ita)
+		if err != nil {
+			t.Fatalf("%v: %v", run.name, err)
+		}
+
+		if got := strings.Join(getter.calls, " | "); got != strings.Join(run.wantCalls, " | ") {
+			t.Errorf("%v: calls = %v, want %v", run.name, got, strings.Join(run.wantCalls, " | "))
+		}
+
+		if got := prNames(prs); got != run.wantPrs {
+			t.Errorf("%v: prs = %v, want %v", run.name, got, run.wantPrs)
+		}
+
+		if !getter.since.IsZero() {
+			t.Errorf("%v: since left set to %v", run.name, getter.since)
+		}
+	}
+
+	from, to, err := st.Synced("org", b.ID)
+	if err != nil || to.IsZero() {
+		t.Fatalf("Synced(org, b) = %v, %v", to, err)
+	}
+
+	// offline, the stored PRs of the selected repos are returned without fetching
+	getter.calls = nil
+	offline := NewOfflineClient(st, &RepoFilter{Exclude: []string{"a"}})
+	offline.SetBase(base)
+	repos, err = offline.GetOrgRepos(ita)
+	if err != nil || len(repos) != 1 || repos[0].Name != "b" {
+		t.Fatalf("offline GetOrgRepos() = %v, %v", repos, err)
+	}
+
+	prs, err := offline.GetPullRequests(repos, ita)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := prNames(prs); got != "b#1" || len(getter.calls) > 0 {
+		t.Errorf("offline prs = %v, calls = %v, want b#1 & no calls", got, getter.calls)
+	}
+
+	// a base before the stored PRs is warned about
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	offline.SetBase(from.AddDate(0, 0, -1))
+	if _, err := offline.GetPullRequests(repos, ita); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logs.String(), "warning: org/b pull requests are only stored from") {
+		t.Errorf("offline logs = %q, want a warning about org/b", logs.String())
+	}
+}
+
+func prNames(prs []*models.PullRequest) string {
+	var names []string
+	for _, pr := range prs {
+		names = append(names, fmt.Sprintf("%v#%v", pr.RepoName, pr.PrNo))
+	}
+
+	return strings.Join(names, " ")
+}
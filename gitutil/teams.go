@@ -0,0 +1,49 @@
+package gitutil
+
+import (
+	"context"
+
+	"github.com/google/go-github/github"
+	"github.com/knishioka/github-pr-stats/models"
+	"github.com/knishioka/github-pr-stats/token"
+)
+
+// GetOrgTeams calls github API and returns the teams of the org along with
+// their members, the members of the teams are fetched by a bounded pool of workers
+func (h *GithubClient) GetOrgTeams(ita token.InsTokenInterface) ([]*models.Team, error) {
+	orgTeams, err := paginate[*github.Team](h, h.getOrgTeamsURL(ita.AccountName()), ita, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	teams := make([]*models.Team, len(orgTeams))
+	err = forEach(h.ctx, h.concurrency, len(orgTeams), func(ctx context.Context, i int) error {
+		hc := h.withContext(ctx)
+		users, err := hc.GetAllUsers(hc.getTeamMembersURL(orgTeams[i].GetID()), ita)
+		if err != nil {
+			return err
+		}
+
+		team := &models.Team{
+			ID:   orgTeams[i].GetID(),
+			Org:  ita.AccountName(),
+			Slug: orgTeams[i].GetSlug(),
+			Name: orgTeams[i].GetName(),
+		}
+
+		for j := 0; j < len(users); j++ {
+			team.Members = append(team.Members, &models.User{
+				ID:       users[j].GetID(),
+				Username: users[j].GetLogin(),
+			})
+		}
+
+		teams[i] = team
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
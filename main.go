@@ -5,11 +5,14 @@ import (
 	"log"
 	"time"
 
+	"github.com/knishioka/github-pr-stats/cache"
 	"github.com/knishioka/github-pr-stats/conf"
 	"github.com/knishioka/github-pr-stats/engine"
 	"github.com/knishioka/github-pr-stats/exporter"
 	"github.com/knishioka/github-pr-stats/gitutil"
+	"github.com/knishioka/github-pr-stats/store"
 	"github.com/knishioka/github-pr-stats/token"
+	"github.com/knishioka/github-pr-stats/transport"
 )
 
 func main() {
@@ -32,18 +35,139 @@ func main() {
 
 	end = end.AddDate(0, 0, 1)
 
+	var baselineStart, baselineEnd time.Time
+	if conf.Configs.BaselineStartDate != "" {
+		baselineStart, err = time.Parse(dateFormat, conf.Configs.BaselineStartDate)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		baselineEnd, err = time.Parse(dateFormat, conf.Configs.BaselineEndDate)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		baselineEnd = baselineEnd.AddDate(0, 0, 1)
+	}
+
 	ctx := context.Background()
-	gitClient := gitutil.NewGithubClient(ctx)
-	exporter := exporter.NewExcelExporter()
-	tokenAgent := token.NewInsTokenAgent(ctx, conf.Configs.InstallationID, conf.Configs.AccountName)
+	httpClient, err := transport.NewClient(transport.Options{
+		MaxRetries: conf.Configs.MaxRetries,
+		CAFile:     conf.Configs.CABundle,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var respCache *cache.Disk
+	if conf.Configs.CacheDir != "" {
+		respCache, err = cache.NewDisk(conf.Configs.CacheDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	repoFilter := &gitutil.RepoFilter{
+		Repos:        conf.Configs.Repos,
+		Include:      conf.Configs.RepoInclude,
+		Exclude:      conf.Configs.RepoExclude,
+		SkipArchived: conf.Configs.SkipArchived,
+		SkipForks:    conf.Configs.SkipForks,
+		Topics:       conf.Configs.RepoTopics,
+		Visibilities: conf.Configs.RepoVisibilities,
+	}
+
+	// with a store every repo of the org is stored and the StoreClient selects them
+	getterFilter := repoFilter
+	if conf.Configs.StorePath != "" {
+		getterFilter = nil
+	}
+
+	gitOpts := gitutil.Options{
+		BaseURL:      conf.Configs.APIBaseURL,
+		Client:       httpClient,
+		Concurrency:  conf.Configs.Concurrency,
+		Cache:        respCache,
+		RepoFilter:   getterFilter,
+		SkipComments: conf.Configs.SkipComments,
+	}
+
+	var gitClient gitutil.GitHelper
+	if conf.Configs.Backend == "graphql" {
+		gitClient = gitutil.NewGraphQLClient(ctx, gitOpts)
+	} else {
+		gitClient = gitutil.NewGithubClient(ctx, gitOpts)
+	}
+
+	var prStore *store.Store
+	if conf.Configs.StorePath != "" {
+		prStore, err = store.Open(conf.Configs.StorePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer prStore.Close()
+
+		if conf.Configs.Offline {
+			gitClient = gitutil.NewOfflineClient(prStore, repoFilter)
+		} else {
+			gitClient = gitutil.NewStoreClient(gitClient, prStore, repoFilter)
+		}
+	}
+
+	exporter, err := exporter.New(conf.Configs.ExportFormats)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// one token agent per org
+	var targets []token.InsTokenInterface
+	if conf.Configs.Offline {
+		accountNames := conf.Configs.AccountNames
+		if conf.Configs.AllInstallations {
+			accountNames, err = prStore.Orgs()
+			if err != nil {
+				log.Fatalf("error listing stored orgs: %v", err.Error())
+			}
+		}
+
+		// the agents only name the orgs, no token is generated offline
+		for _, accountName := range accountNames {
+			targets = append(targets, token.NewStaticTokenAgent(conf.Configs.Token, accountName))
+		}
+	} else if conf.Configs.AllInstallations {
+		targets, err = token.NewInstallationAgents(ctx, httpClient, conf.Configs.APIBaseURL)
+		if err != nil {
+			log.Fatalf("error listing app installations: %v", err.Error())
+		}
+	} else {
+		for _, accountName := range conf.Configs.AccountNames {
+			if conf.Configs.AuthMode == "token" {
+				targets = append(targets, token.NewStaticTokenAgent(conf.Configs.Token, accountName))
+			} else {
+				targets = append(targets, token.NewInsTokenAgent(ctx, httpClient, conf.Configs.APIBaseURL, conf.Configs.InstallationID, accountName))
+			}
+		}
+	}
 
 	engine := engine.Engine{
-		Getter:     gitClient,
-		Exporter:   exporter,
-		TokenAgent: tokenAgent,
-		Start:      start,
-		End:        end,
-		Base:       conf.Configs.Base,
+		Getter:              gitClient,
+		Exporter:            exporter,
+		Targets:             targets,
+		Start:               start,
+		End:                 end,
+		Base:                conf.Configs.Base,
+		MatrixApprovalsOnly: conf.Configs.MatrixApprovalsOnly,
+		Bucket:              conf.Configs.Bucket,
+		BucketFormat:        conf.Configs.BucketFormat,
+		Compare:             conf.Configs.Compare,
+		BaselineStart:       baselineStart,
+		BaselineEnd:         baselineEnd,
+		SizeThresholds:      conf.Configs.SizeThresholds,
+		ExcludeLogins:       conf.Configs.ExcludeLogins,
+		ExcludeBots:         conf.Configs.ExcludeBots,
+		Teams:               conf.Configs.Teams,
+		TeamAttribution:     conf.Configs.TeamAttribution,
+		Offline:             conf.Configs.Offline,
 	}
 
 	if err := engine.Run(); err != nil {
@@ -3,32 +3,175 @@ package models
 import "time"
 
 //User defines a github user
+//The json tags name the fields of the JSON & NDJSON exports
 type User struct {
-	ID                int64
-	Username          string
-	PullReqsCreated   int
-	PullReqsReviewed  int
-	ReviewsOnPullReqs int
-	TotalAdditions    int
-	TotalDeletions    int
-	TotalChangedFiles int
-	TotalCommits      int
+	ID              int64  `json:"id"`
+	Username        string `json:"username"`
+	PullReqsCreated int    `json:"pull_reqs_created"`
+	//PullReqsReviewed is the number of distinct PRs the user reviewed
+	//ReviewSubmissions is the number of reviews the user submitted
+	//Both leave out the reviews on the user's own PRs
+	PullReqsReviewed  int `json:"pull_reqs_reviewed"`
+	ReviewSubmissions int `json:"review_submissions"`
+	ReviewsOnPullReqs int `json:"reviews_on_pull_reqs"`
+	TotalAdditions    int `json:"total_additions"`
+	TotalDeletions    int `json:"total_deletions"`
+	TotalChangedFiles int `json:"total_changed_files"`
+	TotalCommits      int `json:"total_commits"`
+	//PullReqsMerged, PullReqsClosedUnmerged & PullReqsOpen split the
+	//PullReqsCreated by their current state
+	PullReqsMerged         int `json:"pull_reqs_merged"`
+	PullReqsClosedUnmerged int `json:"pull_reqs_closed_unmerged"`
+	PullReqsOpen           int `json:"pull_reqs_open"`
+	//PullReqsMergedBy is the number of PRs the user merged
+	PullReqsMergedBy int `json:"pull_reqs_merged_by"`
+	//Reviews given by the user per review state
+	ReviewsApproved         int `json:"reviews_approved"`
+	ReviewsChangesRequested int `json:"reviews_changes_requested"`
+	ReviewsCommented        int `json:"reviews_commented"`
+	ReviewsDismissed        int `json:"reviews_dismissed"`
+	//PullReqsApproved & PullReqsChangesRequested are the number of the
+	//user's PRs which got at least one approval or change request
+	PullReqsApproved         int `json:"pull_reqs_approved"`
+	PullReqsChangesRequested int `json:"pull_reqs_changes_requested"`
+	//ReviewApprovalRate is the share of the user's reviews which approved
+	ReviewApprovalRate float64 `json:"review_approval_rate"`
+	//PullReqApprovalRate is the share of the user's PRs which got approved
+	PullReqApprovalRate float64 `json:"pull_req_approval_rate"`
+	//CommentsWritten is the number of comments the user wrote on other
+	//users' PRs, CommentsReceived the number written by others on the user's PRs
+	CommentsWritten  int `json:"comments_written"`
+	CommentsReceived int `json:"comments_received"`
+	//CommentsPerReviewedPullReq is the average number of comments the user
+	//wrote on each of the PRs counted in PullReqsReviewed
+	CommentsPerReviewedPullReq float64 `json:"comments_per_reviewed_pull_req"`
+	//SizeCounts splits the PullReqsCreated by size class
+	SizeCounts
+	//MedianPullReqSize is the median lines changed of the PullReqsCreated
+	MedianPullReqSize float64 `json:"median_pull_req_size"`
+	//Teams are the slugs of the teams of the user
+	Teams []string `json:"teams,omitempty"`
+}
+
+//Add adds the counts of o to the counts of the user, the rates, the
+//median & the teams are left as they are
+func (u *User) Add(o *User) {
+	u.PullReqsCreated += o.PullReqsCreated
+	u.PullReqsReviewed += o.PullReqsReviewed
+	u.ReviewSubmissions += o.ReviewSubmissions
+	u.ReviewsOnPullReqs += o.ReviewsOnPullReqs
+	u.TotalAdditions += o.TotalAdditions
+	u.TotalDeletions += o.TotalDeletions
+	u.TotalChangedFiles += o.TotalChangedFiles
+	u.TotalCommits += o.TotalCommits
+	u.PullReqsMerged += o.PullReqsMerged
+	u.PullReqsClosedUnmerged += o.PullReqsClosedUnmerged
+	u.PullReqsOpen += o.PullReqsOpen
+	u.PullReqsMergedBy += o.PullReqsMergedBy
+	u.ReviewsApproved += o.ReviewsApproved
+	u.ReviewsChangesRequested += o.ReviewsChangesRequested
+	u.ReviewsCommented += o.ReviewsCommented
+	u.ReviewsDismissed += o.ReviewsDismissed
+	u.PullReqsApproved += o.PullReqsApproved
+	u.PullReqsChangesRequested += o.PullReqsChangesRequested
+	u.CommentsWritten += o.CommentsWritten
+	u.CommentsReceived += o.CommentsReceived
+	u.PullReqsXS += o.PullReqsXS
+	u.PullReqsS += o.PullReqsS
+	u.PullReqsM += o.PullReqsM
+	u.PullReqsL += o.PullReqsL
+	u.PullReqsXL += o.PullReqsXL
+}
+
+//SizeClasses names the PR size classes from the smallest, by lines changed
+var SizeClasses = []string{"XS", "S", "M", "L", "XL"}
+
+//SizeCounts defines the number of PRs per size class
+type SizeCounts struct {
+	PullReqsXS int `json:"pull_reqs_xs"`
+	PullReqsS  int `json:"pull_reqs_s"`
+	PullReqsM  int `json:"pull_reqs_m"`
+	PullReqsL  int `json:"pull_reqs_l"`
+	PullReqsXL int `json:"pull_reqs_xl"`
+}
+
+//Add counts a PR of the size class at index class of SizeClasses
+func (c *SizeCounts) Add(class int) {
+	switch class {
+	case 0:
+		c.PullReqsXS++
+	case 1:
+		c.PullReqsS++
+	case 2:
+		c.PullReqsM++
+	case 3:
+		c.PullReqsL++
+	default:
+		c.PullReqsXL++
+	}
+}
+
+//RepoStats defines the stats of a github repo
+type RepoStats struct {
+	Org               string `json:"org"`
+	Name              string `json:"name"`
+	PullReqsOpened    int    `json:"pull_reqs_opened"`
+	Reviews           int    `json:"reviews"`
+	TotalAdditions    int    `json:"total_additions"`
+	TotalDeletions    int    `json:"total_deletions"`
+	TotalChangedFiles int    `json:"total_changed_files"`
+	TotalCommits      int    `json:"total_commits"`
+	//Authors is the number of distinct users who opened PRs
+	Authors int `json:"authors"`
+	//Reviewers is the number of distinct users who reviewed PRs
+	Reviewers int `json:"reviewers"`
+	//SizeCounts splits the PullReqsOpened by size class
+	SizeCounts
+	//MedianPullReqSize is the median lines changed of the PullReqsOpened
+	MedianPullReqSize float64 `json:"median_pull_req_size"`
+}
+
+//Team defines a github team of an org
+type Team struct {
+	ID      int64
+	Org     string
+	Slug    string
+	Name    string
+	Members []*User
+}
+
+//TeamStats defines the stats of a github team, the sums of the stats of
+//its members over all the orgs
+type TeamStats struct {
+	Org  string `json:"org"`
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+	//Members is the number of members attributed to the team
+	Members int `json:"members"`
+	//Stats are the rolled up stats, named after the team slug
+	Stats *User `json:"stats"`
 }
 
 //Repo defines a github repo
 type Repo struct {
-	ID       int64
-	Name     string
-	FullName string
+	ID         int64
+	Name       string
+	FullName   string
+	Archived   bool
+	Fork       bool
+	Topics     []string
+	Visibility string
 }
 
 //PullRequest defines a github pr
 type PullRequest struct {
 	ID           int64
+	Org          string
 	RepoID       int64
 	RepoName     string
 	UserID       int64
 	Username     string
+	UserType     string
 	PrNo         int
 	Additions    int
 	Deletions    int
@@ -36,14 +179,206 @@ type PullRequest struct {
 	Commits      int
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
+	//State is either open or closed, a merged PR is closed
+	State        string
+	Draft        bool
+	Merged       bool
+	MergedAt     time.Time
+	ClosedAt     time.Time
+	MergedByID   int64
+	MergedBy     string
+	MergedByType string
 	Reviews      []*Review
+	Comments     []*Comment
+}
+
+//Lines returns the lines changed by the PR
+func (pr *PullRequest) Lines() int {
+	return pr.Additions + pr.Deletions
+}
+
+//FirstReviewAt returns when the PR got its first review from another user
+//than its author, zero if it didn't get any
+func (pr *PullRequest) FirstReviewAt() (at time.Time) {
+	for _, review := range pr.Reviews {
+		if review.UserID == pr.UserID || review.SubmittedAt.IsZero() {
+			continue
+		}
+
+		if at.IsZero() || review.SubmittedAt.Before(at) {
+			at = review.SubmittedAt
+		}
+	}
+
+	return at
+}
+
+//ApprovedAt returns when the PR was first approved, zero if it wasn't
+func (pr *PullRequest) ApprovedAt() (at time.Time) {
+	for _, review := range pr.Reviews {
+		if review.State != ReviewApproved || review.SubmittedAt.IsZero() {
+			continue
+		}
+
+		if at.IsZero() || review.SubmittedAt.Before(at) {
+			at = review.SubmittedAt
+		}
+	}
+
+	return at
 }
 
+//Review states
+const (
+	ReviewApproved         = "APPROVED"
+	ReviewChangesRequested = "CHANGES_REQUESTED"
+	ReviewCommented        = "COMMENTED"
+	ReviewDismissed        = "DISMISSED"
+)
+
 //Review defines a review on github pr
 type Review struct {
 	ID          int64
 	State       string
 	UserID      int64
 	Username    string
+	UserType    string
 	SubmittedAt time.Time
 }
+
+//Comment kinds
+const (
+	//CommentReview is an inline comment on the diff of a PR
+	CommentReview = "review"
+	//CommentIssue is a comment on the conversation of a PR
+	CommentIssue = "issue"
+)
+
+//Comment defines a comment on github pr
+type Comment struct {
+	ID        int64
+	Kind      string
+	UserID    int64
+	Username  string
+	UserType  string
+	CreatedAt time.Time
+}
+
+//Report defines the stats generated by a run over one or more orgs
+type Report struct {
+	Orgs        []string
+	Start       time.Time
+	End         time.Time
+	GeneratedAt time.Time
+	//Excluded counts what was left out of the stats by the exclusion rules
+	Excluded Exclusions
+	//Users holds the stats of each user over all the orgs,
+	//users are matched by their github user ID
+	Users []*User
+	//BaselineUsers holds the stats of each user over the baseline window
+	//the report is compared to, nil without a comparison
+	BaselineStart time.Time
+	BaselineEnd   time.Time
+	BaselineUsers []*User
+	//OrgUsers holds the stats of each user per org
+	OrgUsers map[string][]*User
+	//Repos holds the stats of each repo
+	Repos []*RepoStats
+	//PullRequests holds the PRs created, reviewed or commented within the window
+	PullRequests []*PullRequest
+	//Latency holds the review & merge latencies per author, reviewer & repo
+	Latency []*LatencyStats
+	//Sizes holds how the PRs of each size class got reviewed
+	Sizes []*SizeStats
+	//Teams holds the stats of each team, rolled up from its members
+	Teams []*TeamStats
+	//Interactions holds the number of reviews per reviewer & author,
+	//only the approvals when ApprovalsOnly is set
+	Interactions  []*Interaction
+	ApprovalsOnly bool
+	//Buckets holds the stats of each user per day, week or month of the
+	//window as set in Bucket, BucketFormat is the layout to export them in
+	Bucket       string
+	BucketFormat string
+	Buckets      []*Bucket
+}
+
+//InWindow tells whether t is within the report window: after Start, up to End
+func (r *Report) InWindow(t time.Time) bool {
+	return r.Start.Before(t) && !r.End.Before(t)
+}
+
+//Latency summarizes a set of durations, in hours
+type Latency struct {
+	Count  int     `json:"count"`
+	Median float64 `json:"median_hours"`
+	P75    float64 `json:"p75_hours"`
+	P90    float64 `json:"p90_hours"`
+	Max    float64 `json:"max_hours"`
+}
+
+//LatencyStats defines the latencies of the PRs of an author or a repo,
+//for a reviewer they are measured up to the reviewer's own first review
+//and approval of each PR
+type LatencyStats struct {
+	//Scope is either author, reviewer or repo
+	Scope string `json:"scope"`
+	//Name is the username or the org/repo
+	Name              string  `json:"name"`
+	TimeToFirstReview Latency `json:"time_to_first_review"`
+	TimeToApproval    Latency `json:"time_to_approval"`
+	TimeToMerge       Latency `json:"time_to_merge"`
+}
+
+//Interaction defines the number of reviews a reviewer gave on the PRs of an author
+type Interaction struct {
+	ReviewerID int64  `json:"reviewer_id"`
+	Reviewer   string `json:"reviewer"`
+	AuthorID   int64  `json:"author_id"`
+	Author     string `json:"author"`
+	Reviews    int    `json:"reviews"`
+}
+
+//Bucket defines the stats of each user over a part of the report window
+type Bucket struct {
+	//Label is the day, the ISO week or the month, e.g. 2024-01-31, 2024-W05 or 2024-01
+	Label string    `json:"label"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Users []*User   `json:"users"`
+}
+
+//SizeStats defines how the PRs of a size class created within the window got reviewed
+type SizeStats struct {
+	Class string `json:"class"`
+	//MinLines & MaxLines bound the lines changed of the class,
+	//MaxLines is 0 for the largest class
+	MinLines int `json:"min_lines"`
+	MaxLines int `json:"max_lines"`
+	PullReqs int `json:"pull_reqs"`
+	//Reviewed is the number of PRs reviewed by another user than their author
+	Reviewed            int     `json:"reviewed"`
+	ReviewsPerPullReq   float64 `json:"reviews_per_pull_req"`
+	ReviewersPerPullReq float64 `json:"reviewers_per_pull_req"`
+	TimeToFirstReview   Latency `json:"time_to_first_review"`
+	TimeToMerge         Latency `json:"time_to_merge"`
+}
+
+//UserTypeBot is the account type of Github Apps & bots, the UserType of
+//the models is the github account type: User, Bot or Organization
+const UserTypeBot = "Bot"
+
+//Exclusions defines what was left out of the stats by the exclusion rules
+type Exclusions struct {
+	//Logins are the excluded users who had any activity
+	Logins   []string `json:"logins,omitempty"`
+	PullReqs int      `json:"pull_reqs"`
+	Reviews  int      `json:"reviews"`
+	Comments int      `json:"comments"`
+	Members  int      `json:"members"`
+}
+
+//Empty tells whether nothing was excluded
+func (x *Exclusions) Empty() bool {
+	return len(x.Logins) == 0 && x.Members == 0
+}
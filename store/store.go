@@ -0,0 +1,260 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/knishioka/github-pr-stats/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets of each org, the orgs are the top level buckets
+var (
+	reposBucket        = []byte("repos")
+	usersBucket        = []byte("users")
+	teamsBucket        = []byte("teams")
+	pullRequestsBucket = []byte("pull_requests")
+	metaBucket         = []byte("meta")
+)
+
+// Names of the sync dates of each repo in the meta bucket
+const (
+	syncedFrom = "synced_from"
+	syncedTo   = "synced_to"
+)
+
+// Store is a local database of the repos, members, teams & PRs along with
+// their reviews & comments fetched from github, per org
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the store at path, creating it if needed
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open store: %v: %v", path, err.Error())
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the store
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Orgs returns the orgs found in the store
+func (s *Store) Orgs() (orgs []string, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			orgs = append(orgs, string(name))
+			return nil
+		})
+	})
+
+	return orgs, err
+}
+
+// PutRepos replaces the repos of org
+func (s *Store) PutRepos(org string, repos []*models.Repo) error {
+	return replace(s, org, reposBucket, len(repos), func(i int) ([]byte, interface{}) {
+		return idKey(repos[i].ID), repos[i]
+	})
+}
+
+// Repos returns the repos of org
+func (s *Store) Repos(org string) (repos []*models.Repo, err error) {
+	return list[*models.Repo](s, org, reposBucket)
+}
+
+// PutUsers replaces the members of org
+func (s *Store) PutUsers(org string, users []*models.User) error {
+	return replace(s, org, usersBucket, len(users), func(i int) ([]byte, interface{}) {
+		return idKey(users[i].ID), users[i]
+	})
+}
+
+// Users returns the members of org
+func (s *Store) Users(org string) (users []*models.User, err error) {
+	return list[*models.User](s, org, usersBucket)
+}
+
+// PutTeams replaces the teams of org
+func (s *Store) PutTeams(org string, teams []*models.Team) error {
+	return replace(s, org, teamsBucket, len(teams), func(i int) ([]byte, interface{}) {
+		return idKey(teams[i].ID), teams[i]
+	})
+}
+
+// Teams returns the teams of org
+func (s *Store) Teams(org string) (teams []*models.Team, err error) {
+	return list[*models.Team](s, org, teamsBucket)
+}
+
+// PutPullRequests adds the PRs of org or replaces the stored ones,
+// the other PRs are kept
+func (s *Store) PutPullRequests(org string, prs []*models.PullRequest) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := bucket(tx, org, pullRequestsBucket)
+		if err != nil {
+			return err
+		}
+
+		for _, pr := range prs {
+			value, err := json.Marshal(pr)
+			if err != nil {
+				return err
+			}
+
+			if err := b.Put(prKey(pr.RepoID, pr.PrNo), value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// PullRequests returns the PRs of org ordered by repo ID & number
+func (s *Store) PullRequests(org string) (prs []*models.PullRequest, err error) {
+	return list[*models.PullRequest](s, org, pullRequestsBucket)
+}
+
+// SetSynced records that the PRs of the repo repoID of org created since from,
+// or updated since then when fetched by last update, were fetched up to to
+func (s *Store) SetSynced(org string, repoID int64, from, to time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := bucket(tx, org, metaBucket)
+		if err != nil {
+			return err
+		}
+
+		put := func(key []byte, at time.Time) error {
+			value, err := at.MarshalText()
+			if err != nil {
+				return err
+			}
+
+			return b.Put(key, value)
+		}
+
+		if err := put(syncedKey(repoID, syncedFrom), from); err != nil {
+			return err
+		}
+
+		return put(syncedKey(repoID, syncedTo), to)
+	})
+}
+
+// Synced returns the dates recorded by SetSynced, zero if the repo repoID
+// of org was never synced
+func (s *Store) Synced(org string, repoID int64) (from, to time.Time, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		orgBucket := tx.Bucket([]byte(org))
+		if orgBucket == nil || orgBucket.Bucket(metaBucket) == nil {
+			return nil
+		}
+
+		b := orgBucket.Bucket(metaBucket)
+		if value := b.Get(syncedKey(repoID, syncedFrom)); value != nil {
+			if err := from.UnmarshalText(value); err != nil {
+				return err
+			}
+		}
+
+		if value := b.Get(syncedKey(repoID, syncedTo)); value != nil {
+			if err := to.UnmarshalText(value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return from, to, err
+}
+
+// bucket returns the bucket name of org, creating both if needed
+func bucket(tx *bolt.Tx, org string, name []byte) (*bolt.Bucket, error) {
+	orgBucket, err := tx.CreateBucketIfNotExists([]byte(org))
+	if err != nil {
+		return nil, fmt.Errorf("create store bucket: %v: %v", org, err.Error())
+	}
+
+	b, err := orgBucket.CreateBucketIfNotExists(name)
+	if err != nil {
+		return nil, fmt.Errorf("create store bucket: %v/%s: %v", org, name, err.Error())
+	}
+
+	return b, nil
+}
+
+// replace empties the bucket name of org and stores the n items returned by item
+func replace(s *Store, org string, name []byte, n int, item func(i int) ([]byte, interface{})) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if orgBucket := tx.Bucket([]byte(org)); orgBucket != nil && orgBucket.Bucket(name) != nil {
+			if err := orgBucket.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+
+		b, err := bucket(tx, org, name)
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < n; i++ {
+			key, v := item(i)
+			value, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+
+			if err := b.Put(key, value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// list returns the items of the bucket name of org in key order
+func list[T any](s *Store, org string, name []byte) (items []T, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		orgBucket := tx.Bucket([]byte(org))
+		if orgBucket == nil || orgBucket.Bucket(name) == nil {
+			return nil
+		}
+
+		return orgBucket.Bucket(name).ForEach(func(_, value []byte) error {
+			var item T
+			if err := json.Unmarshal(value, &item); err != nil {
+				return fmt.Errorf("store: %v/%s unmarshal error: %v", org, name, err)
+			}
+
+			items = append(items, item)
+			return nil
+		})
+	})
+
+	return items, err
+}
+
+// idKey returns the key of a github ID, zero padded to keep the ID order
+func idKey(id int64) []byte {
+	return []byte(fmt.Sprintf("%020d", id))
+}
+
+// prKey returns the key of a PR, ordered by repo ID & number,
+// the repo ID is kept when the repo is renamed
+func prKey(repoID int64, number int) []byte {
+	return []byte(fmt.Sprintf("%020d/%010d", repoID, number))
+}
+
+// syncedKey returns the key of a sync date of a repo in the meta bucket
+func syncedKey(repoID int64, name string) []byte {
+	return []byte(fmt.Sprintf("%020d/%v", repoID, name))
+}
@@ -7,12 +7,16 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"time"
+	"strings"
+	"sync"
 )
 
 // InsTokenInterface represents an agent to obtain, use, storage of installation access tokens
 type InsTokenInterface interface {
 	GenerateNew() error
+	// Renew replaces the token rejected by a 401 unless another caller
+	// already did, so that concurrent 401s renew the token once
+	Renew(rejected string) error
 	AccountName() string
 	Bearer() string
 }
@@ -21,20 +25,28 @@ type InsTokenInterface interface {
 type InsTokenAgent struct {
 	installationID int64
 	accountName    string
+	baseURL        string
 	token          string
 	c              *http.Client
 	ta             JWTInterface
+	// mutex guards token & installationID, GithubClient workers may renew it concurrently
+	mutex *sync.RWMutex
+	// renewal serializes the renewals of Renew
+	renewal *sync.Mutex
 }
 
 //NewInsTokenAgent returns a GitHelper
-func NewInsTokenAgent(ctx context.Context, installationID int64, accName string) InsTokenInterface {
+//baseURL is the API root, e.g. https://github.example.com/api/v3 for Github Enterprise Server
+//If installationID is 0 the installation of the app on accName is looked up
+func NewInsTokenAgent(ctx context.Context, c *http.Client, baseURL string, installationID int64, accName string) InsTokenInterface {
 	return &InsTokenAgent{
-		c: &http.Client{
-			Timeout: time.Second * 23,
-		},
+		c:              c,
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
 		ta:             NewJWTAgent(ctx),
 		installationID: installationID,
 		accountName:    accName,
+		mutex:          &sync.RWMutex{},
+		renewal:        &sync.Mutex{},
 	}
 }
 
@@ -45,17 +57,46 @@ func (h *InsTokenAgent) AccountName() string {
 
 //Bearer returns installation access token
 func (h *InsTokenAgent) Bearer() string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
 	return h.token
 }
 
-func (h *InsTokenAgent) getInstallationTokenURL() string {
-	return fmt.Sprintf("https://api.github.com/app/installations/%v/access_tokens", h.installationID)
+//Renew generates a new installation token unless rejected was already replaced
+func (h *InsTokenAgent) Renew(rejected string) error {
+	h.renewal.Lock()
+	defer h.renewal.Unlock()
+	if h.Bearer() != rejected {
+		return nil
+	}
+
+	return h.GenerateNew()
+}
+
+func (h *InsTokenAgent) getInstallationTokenURL(installationID int64) string {
+	return fmt.Sprintf("%v/app/installations/%v/access_tokens", h.baseURL, installationID)
 }
 
 // GenerateNew generate new installation token
 func (h *InsTokenAgent) GenerateNew() error {
+	h.mutex.RLock()
+	installationID := h.installationID
+	h.mutex.RUnlock()
+
+	if installationID == 0 {
+		id, err := h.resolveInstallationID()
+		if err != nil {
+			return err
+		}
+
+		h.mutex.Lock()
+		h.installationID = id
+		h.mutex.Unlock()
+		installationID = id
+	}
+
 	data := make(map[string]interface{})
-	req, err := http.NewRequest("POST", h.getInstallationTokenURL(), &bytes.Buffer{})
+	req, err := http.NewRequest("POST", h.getInstallationTokenURL(installationID), &bytes.Buffer{})
 	if err != nil {
 		return fmt.Errorf("create new HTTP request: %v", err.Error())
 	}
@@ -86,7 +127,9 @@ func (h *InsTokenAgent) GenerateNew() error {
 		return fmt.Errorf("token not available in response: %v", data)
 	}
 
+	h.mutex.Lock()
 	h.token = fmt.Sprintf("%s", data["token"])
+	h.mutex.Unlock()
 
 	return nil
 }
@@ -0,0 +1,74 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeJWT is a JWTInterface with a fixed app token
+type fakeJWT struct{}
+
+func (fakeJWT) ScheduleRenewal(context.Context) {}
+func (fakeJWT) Bearer() string                  { return "jwt" }
+func (fakeJWT) Renew() error                    { return nil }
+
+func TestRenewOnceForConcurrentRejections(t *testing.T) {
+	var minted int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&minted, 1)
+		fmt.Fprintf(w, `{"token": "token-%v"}`, n)
+	}))
+	defer srv.Close()
+
+	agent := &InsTokenAgent{
+		c:              srv.Client(),
+		baseURL:        srv.URL,
+		ta:             fakeJWT{},
+		installationID: 1,
+		accountName:    "org",
+		token:          "token-0",
+		mutex:          &sync.RWMutex{},
+		renewal:        &sync.Mutex{},
+	}
+
+	// the workers got a 401 with the same token
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := agent.Renew("token-0"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if minted != 1 || agent.Bearer() != "token-1" {
+		t.Errorf("minted %v tokens, bearer = %v, want 1 & token-1", minted, agent.Bearer())
+	}
+
+	// a rejection of the renewed token renews it again
+	if err := agent.Renew("token-1"); err != nil {
+		t.Fatal(err)
+	}
+	if minted != 2 || agent.Bearer() != "token-2" {
+		t.Errorf("minted %v tokens, bearer = %v, want 2 & token-2", minted, agent.Bearer())
+	}
+}
+
+func TestStaticTokenRenew(t *testing.T) {
+	agent := NewStaticTokenAgent("token", "org")
+	if err := agent.GenerateNew(); err != nil {
+		t.Fatalf("GenerateNew() error = %v", err)
+	}
+
+	if err := agent.Renew(agent.Bearer()); err != ErrTokenRejected {
+		t.Errorf("Renew() error = %v, want ErrTokenRejected", err)
+	}
+}
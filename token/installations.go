@@ -0,0 +1,130 @@
+package token
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/knishioka/github-pr-stats/transport"
+)
+
+// installation is an installation of the Github App on an account
+type installation struct {
+	ID      int64 `json:"id"`
+	Account struct {
+		Login string `json:"login"`
+	} `json:"account"`
+}
+
+// Github API Docs: https://docs.github.com/en/rest/apps/apps#list-installations-for-the-authenticated-app
+func getAppInstallationsURL(baseURL string) string {
+	return fmt.Sprintf("%v/app/installations?per_page=100", baseURL)
+}
+
+// Github API Docs: https://docs.github.com/en/rest/apps/apps#get-an-organization-installation-for-the-authenticated-app
+func getOrgInstallationURL(baseURL, accName string) string {
+	return fmt.Sprintf("%v/orgs/%v/installation", baseURL, accName)
+}
+
+// Github API Docs: https://docs.github.com/en/rest/apps/apps#get-a-user-installation-for-the-authenticated-app
+func getUserInstallationURL(baseURL, accName string) string {
+	return fmt.Sprintf("%v/users/%v/installation", baseURL, accName)
+}
+
+// NewInstallationAgents lists every installation of the Github App and returns
+// an InsTokenAgent for each of them, the agents share a single JWT agent
+func NewInstallationAgents(ctx context.Context, c *http.Client, baseURL string) ([]InsTokenInterface, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	ta := NewJWTAgent(ctx)
+
+	var agents []InsTokenInterface
+	uri := getAppInstallationsURL(baseURL)
+	for uri != "" {
+		body, status, next, err := getAsApp(c, ta, uri)
+		if err != nil {
+			return nil, err
+		}
+
+		if status != 200 {
+			return nil, fmt.Errorf("list app installations error: unexpected response status %v", status)
+		}
+
+		var installations []installation
+		if err := json.Unmarshal(body, &installations); err != nil {
+			return nil, fmt.Errorf("app installations unmarshal error: %v", err)
+		}
+
+		for i := 0; i < len(installations); i++ {
+			agents = append(agents, &InsTokenAgent{
+				c:              c,
+				baseURL:        baseURL,
+				ta:             ta,
+				installationID: installations[i].ID,
+				accountName:    installations[i].Account.Login,
+				mutex:          &sync.RWMutex{},
+			})
+		}
+
+		uri = next
+	}
+
+	return agents, nil
+}
+
+// resolveInstallationID finds the installation of the Github App on the
+// account, which can be either an organization or a user
+func (h *InsTokenAgent) resolveInstallationID() (int64, error) {
+	for _, uri := range []string{getOrgInstallationURL(h.baseURL, h.accountName), getUserInstallationURL(h.baseURL, h.accountName)} {
+		body, status, _, err := getAsApp(h.c, h.ta, uri)
+		if err != nil {
+			return 0, err
+		}
+
+		if status == 404 {
+			continue
+		}
+
+		if status != 200 {
+			return 0, fmt.Errorf("get app installation error: unexpected response status %v", status)
+		}
+
+		var ins installation
+		if err := json.Unmarshal(body, &ins); err != nil {
+			return 0, fmt.Errorf("app installation unmarshal error: %v", err)
+		}
+
+		return ins.ID, nil
+	}
+
+	return 0, fmt.Errorf("github app is not installed on %v", h.accountName)
+}
+
+// getAsApp makes a GET request authenticated as the Github App and returns
+// the body & status of the response along with the URL of the next page
+func getAsApp(c *http.Client, ta JWTInterface, uri string) (body []byte, status int, next string, err error) {
+	req, err := http.NewRequest("GET", uri, &bytes.Buffer{})
+	if err != nil {
+		return body, status, next, fmt.Errorf("create new HTTP request: %v", err.Error())
+	}
+
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %v", ta.Bearer()))
+	req.Header.Add("Accept", "application/vnd.github.machine-man-preview+json")
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return body, status, next, fmt.Errorf("make request error: %v", err.Error())
+	}
+	defer resp.Body.Close()
+
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return body, status, next, fmt.Errorf("read body error: %v", err.Error())
+	}
+
+	return body, resp.StatusCode, transport.NextPageURL(resp.Header.Get("Link")), nil
+}
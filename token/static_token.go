@@ -0,0 +1,47 @@
+package token
+
+import "errors"
+
+// ErrTokenRejected is returned when a request got a 401 with a static token,
+// unlike installation tokens it can't be renewed
+var ErrTokenRejected = errors.New("static token rejected with 401 Unauthorized, check that it is valid and not expired")
+
+// StaticTokenAgent implements InsTokenInterface with a fixed token,
+// e.g. a personal access token or the GITHUB_TOKEN of a Github Actions run
+type StaticTokenAgent struct {
+	token       string
+	accountName string
+}
+
+// NewStaticTokenAgent returns a StaticTokenAgent as InsTokenInterface
+func NewStaticTokenAgent(token, accName string) InsTokenInterface {
+	return &StaticTokenAgent{
+		token:       token,
+		accountName: accName,
+	}
+}
+
+// AccountName returns the account name the token is used for
+func (a *StaticTokenAgent) AccountName() string {
+	return a.accountName
+}
+
+// Bearer returns the static token
+func (a *StaticTokenAgent) Bearer() string {
+	return a.token
+}
+
+// GenerateNew checks that a token is set
+func (a *StaticTokenAgent) GenerateNew() error {
+	if a.token == "" {
+		return errors.New("no static token set")
+	}
+
+	return nil
+}
+
+// Renew is called after a 401, which means the token was rejected:
+// ErrTokenRejected is returned instead of retrying with the same token
+func (a *StaticTokenAgent) Renew(rejected string) error {
+	return ErrTokenRejected
+}
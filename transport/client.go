@@ -0,0 +1,62 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// DefaultBaseURL is the root of the github.com REST API
+const DefaultBaseURL = "https://api.github.com"
+
+// Options configures the HTTP client used for the GitHub API
+type Options struct {
+	// MaxRetries is the max number of retries of a single request
+	MaxRetries int
+	// CAFile is a PEM bundle of extra CAs to trust, e.g. the one
+	// signing the certificate of a Github Enterprise Server
+	CAFile string
+}
+
+// NewClient returns an http.Client whose requests go through a RateLimit transport.
+// The timeout applies to each attempt rather than to the whole request,
+// so that waiting for a rate limit reset doesn't time the request out.
+func NewClient(opts Options) (*http.Client, error) {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.ResponseHeaderTimeout = time.Second * 23
+
+	if opts.CAFile != "" {
+		pool, err := loadCAs(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		base.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &http.Client{
+		Transport: NewRateLimit(base, opts.MaxRetries),
+	}, nil
+}
+
+// loadCAs returns the system cert pool with the certificates of file added
+func loadCAs(file string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read CA bundle: %v", err.Error())
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in CA bundle %v", file)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,61 @@
+package transport
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewClientCAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, cert, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// without the CA bundle the stand-in's certificate is unknown
+	client, err := NewClient(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Fatal("Get() without CAFile error = nil, want an unknown authority")
+	}
+
+	client, err = NewClient(Options{CAFile: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get() with CAFile error = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %v, want 200", resp.StatusCode)
+	}
+}
+
+func TestNewClientInvalidCAFile(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.pem")
+	if err := ioutil.WriteFile(empty, []byte("no certificate here"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, caFile := range []string{filepath.Join(dir, "missing.pem"), empty} {
+		if _, err := NewClient(Options{CAFile: caFile}); err == nil {
+			t.Errorf("NewClient(%v) error = nil, want an error", caFile)
+		}
+	}
+}
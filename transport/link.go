@@ -0,0 +1,31 @@
+package transport
+
+import "strings"
+
+// NextPageURL returns the rel="next" target of an RFC 5988 Link header value,
+// or an empty string if there is none.
+// e.g. <https://api.github.com/orgs/x/repos?page=2>; rel="next", <...>; rel="last"
+func NextPageURL(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		segments := strings.Split(part, ";")
+		target := strings.TrimSpace(segments[0])
+		if len(segments) < 2 || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+
+		for _, param := range segments[1:] {
+			key, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(key) != "rel" {
+				continue
+			}
+
+			for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(val), `"`)) {
+				if rel == "next" {
+					return target[1 : len(target)-1]
+				}
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,47 @@
+package transport
+
+import "testing"
+
+func TestNextPageURL(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		want string
+	}{
+		{name: "empty"},
+		{
+			name: "next & last",
+			link: `<https://api.github.com/orgs/x/repos?page=2>; rel="next", <https://api.github.com/orgs/x/repos?page=5>; rel="last"`,
+			want: "https://api.github.com/orgs/x/repos?page=2",
+		},
+		{
+			name: "next not first",
+			link: `<https://api.github.com/orgs/x/repos?page=1>; rel="prev", <https://api.github.com/orgs/x/repos?page=3>; rel="next"`,
+			want: "https://api.github.com/orgs/x/repos?page=3",
+		},
+		{
+			name: "last page",
+			link: `<https://api.github.com/orgs/x/repos?page=4>; rel="prev", <https://api.github.com/orgs/x/repos?page=1>; rel="first"`,
+		},
+		{
+			name: "several rels",
+			link: `<https://api.github.com/x?page=2>; rel="next last"`,
+			want: "https://api.github.com/x?page=2",
+		},
+		{
+			name: "unquoted rel & extra params",
+			link: `<https://github.example.com/api/v3/x?page=2>; type="text/html"; rel=next`,
+			want: "https://github.example.com/api/v3/x?page=2",
+		},
+		{name: "no brackets", link: `https://api.github.com/x?page=2; rel="next"`},
+		{name: "no params", link: `<https://api.github.com/x?page=2>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NextPageURL(tt.link); got != tt.want {
+				t.Errorf("NextPageURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
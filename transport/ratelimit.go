@@ -0,0 +1,260 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minBackoff is the wait before the first retry of a failed request
+	minBackoff = time.Second
+	// maxBackoff caps the exponential backoff between retries
+	maxBackoff = time.Minute
+)
+
+// limit is the last known state of a rate limit resource
+type limit struct {
+	limit     int
+	remaining int
+	reset     time.Time
+}
+
+// RateLimit is an http.RoundTripper which keeps requests within the GitHub
+// API rate limits. It reads the X-RateLimit-* headers of every response and
+// waits for the reset once the budget is exhausted, slowing down before that.
+// Rate limited (403/429), abuse detection and 5xx responses are retried.
+type RateLimit struct {
+	// Base makes the actual requests, http.DefaultTransport if nil
+	Base http.RoundTripper
+	// MaxRetries is the max number of retries of a single request
+	MaxRetries int
+	// MinRemaining is the number of requests kept in reserve, once the
+	// remaining budget drops to it requests wait for the reset
+	MinRemaining int
+
+	mutex  sync.Mutex
+	limits map[string]*limit
+}
+
+// NewRateLimit returns a RateLimit wrapping base
+func NewRateLimit(base http.RoundTripper, maxRetries int) *RateLimit {
+	return &RateLimit{
+		Base:         base,
+		MaxRetries:   maxRetries,
+		MinRemaining: 10,
+		limits:       make(map[string]*limit),
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RateLimit) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	resource := resourceOf(req)
+	for attempt := 0; ; attempt++ {
+		if err := sleep(ctx, t.throttle(resource)); err != nil {
+			return nil, err
+		}
+
+		r, err := rewind(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := t.base().RoundTrip(r)
+		var wait time.Duration
+		var reason string
+		if err != nil {
+			if ctx.Err() != nil || attempt >= t.MaxRetries {
+				return nil, err
+			}
+
+			wait, reason = backoff(attempt), err.Error()
+		} else {
+			t.update(resource, resp.Header)
+
+			var retry bool
+			wait, retry = t.retryAfter(resp, attempt)
+			if !retry || attempt >= t.MaxRetries {
+				return resp, nil
+			}
+
+			reason = resp.Status
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		log.Printf("github api: %v %v: %v, retry %v/%v in %v", req.Method, req.URL.Path, reason, attempt+1, t.MaxRetries, wait.Round(time.Millisecond))
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (t *RateLimit) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+
+	return t.Base
+}
+
+// throttle returns how long a request to resource should wait before being sent
+func (t *RateLimit) throttle(resource string) time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	l := t.limits[resource]
+	if l == nil {
+		return 0
+	}
+
+	untilReset := time.Until(l.reset)
+	if untilReset <= 0 {
+		return 0
+	}
+
+	if l.remaining <= t.MinRemaining {
+		log.Printf("github api: %v rate limit exhausted (%v left), waiting %v for the reset", resource, l.remaining, untilReset.Round(time.Second))
+		return untilReset + time.Second
+	}
+
+	// spread the last 10% of the budget over the time left until the reset
+	if l.remaining < l.limit/10 {
+		return untilReset / time.Duration(l.remaining-t.MinRemaining)
+	}
+
+	return 0
+}
+
+// update records the rate limit headers of a response
+func (t *RateLimit) update(resource string, header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+
+	l := &limit{remaining: remaining}
+	l.limit, _ = strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		l.reset = time.Unix(reset, 0)
+	}
+
+	if res := header.Get("X-RateLimit-Resource"); res != "" {
+		resource = res
+	}
+
+	t.mutex.Lock()
+	t.limits[resource] = l
+	t.mutex.Unlock()
+}
+
+// retryAfter tells whether resp should be retried and how long to wait before
+func (t *RateLimit) retryAfter(resp *http.Response, attempt int) (time.Duration, bool) {
+	switch {
+	case resp.StatusCode >= 500:
+		return backoff(attempt), true
+	case resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests:
+		return 0, false
+	}
+
+	// secondary rate limits come with a Retry-After in seconds
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	// primary rate limit exhausted, wait for the reset
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		if err == nil {
+			return time.Until(time.Unix(reset, 0)) + time.Second, true
+		}
+
+		return backoff(attempt), true
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || isAbuse(resp) {
+		return backoff(attempt), true
+	}
+
+	return 0, false
+}
+
+// isAbuse tells whether a 403 response is an abuse detection/secondary rate
+// limit one, the body is left readable for the caller
+func isAbuse(resp *http.Response) bool {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+
+	msg := strings.ToLower(string(body))
+	return strings.Contains(msg, "secondary rate limit") || strings.Contains(msg, "abuse")
+}
+
+// backoff returns a jittered exponential backoff for the given attempt
+func backoff(attempt int) time.Duration {
+	d := maxBackoff
+	if attempt < 6 {
+		d = minBackoff << uint(attempt)
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+// rewind returns the request to send for the given attempt, a retried request
+// gets a fresh copy of its body
+func rewind(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("retry %v %v: request body can't be rewound", req.Method, req.URL)
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	r := req.Clone(req.Context())
+	r.Body = body
+	return r, nil
+}
+
+// resourceOf returns the rate limit resource a request is counted against
+func resourceOf(req *http.Request) string {
+	if strings.HasSuffix(req.URL.Path, "/graphql") {
+		return "graphql"
+	}
+
+	return "core"
+}
+
+// sleep waits for d unless ctx is done first
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
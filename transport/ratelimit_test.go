@@ -0,0 +1,212 @@
+package transport
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	for attempt := 0; attempt < 10; attempt++ {
+		max := maxBackoff
+		if attempt < 6 {
+			max = minBackoff << uint(attempt)
+		}
+
+		for i := 0; i < 20; i++ {
+			if got := backoff(attempt); got < max/2 || got >= max {
+				t.Fatalf("backoff(%v) = %v, want in [%v, %v)", attempt, got, max/2, max)
+			}
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	reset := time.Now().Add(time.Minute)
+	tests := []struct {
+		name    string
+		status  int
+		header  map[string]string
+		body    string
+		retry   bool
+		minWait time.Duration
+		maxWait time.Duration
+	}{
+		{name: "ok", status: 200},
+		{name: "not found", status: 404},
+		{name: "server error", status: 502, retry: true, minWait: minBackoff / 2, maxWait: minBackoff},
+		{name: "retry after seconds", status: 403, header: map[string]string{"Retry-After": "7"}, retry: true, minWait: 7 * time.Second, maxWait: 7 * time.Second},
+		{name: "429 retry after", status: 429, header: map[string]string{"Retry-After": "3"}, retry: true, minWait: 3 * time.Second, maxWait: 3 * time.Second},
+		{
+			name:    "primary limit exhausted",
+			status:  403,
+			header:  map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": strconv.FormatInt(reset.Unix(), 10)},
+			retry:   true,
+			minWait: 55 * time.Second,
+			maxWait: 62 * time.Second,
+		},
+		{name: "primary limit exhausted without reset", status: 403, header: map[string]string{"X-RateLimit-Remaining": "0"}, retry: true, minWait: minBackoff / 2, maxWait: minBackoff},
+		{name: "429 without headers", status: 429, retry: true, minWait: minBackoff / 2, maxWait: minBackoff},
+		{name: "secondary rate limit", status: 403, body: `{"message":"You have exceeded a secondary rate limit."}`, retry: true, minWait: minBackoff / 2, maxWait: minBackoff},
+		{name: "abuse detection", status: 403, body: `{"message":"You have triggered an abuse detection mechanism."}`, retry: true, minWait: minBackoff / 2, maxWait: minBackoff},
+		{name: "forbidden", status: 403, body: `{"message":"Resource not accessible by integration"}`},
+	}
+
+	rl := NewRateLimit(nil, 3)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: tt.status,
+				Header:     make(http.Header),
+				Body:       ioutil.NopCloser(strings.NewReader(tt.body)),
+			}
+			for key, val := range tt.header {
+				resp.Header.Set(key, val)
+			}
+
+			wait, retry := rl.retryAfter(resp, 0)
+			if retry != tt.retry {
+				t.Fatalf("retryAfter() retry = %v, want %v", retry, tt.retry)
+			}
+
+			if retry && (wait < tt.minWait || wait > tt.maxWait) {
+				t.Errorf("retryAfter() wait = %v, want in [%v, %v]", wait, tt.minWait, tt.maxWait)
+			}
+
+			// the body stays readable for the caller
+			body, _ := ioutil.ReadAll(resp.Body)
+			if string(body) != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	future := time.Now().Add(100 * time.Second)
+	tests := []struct {
+		name    string
+		limit   *limit
+		minWait time.Duration
+		maxWait time.Duration
+	}{
+		{name: "unknown"},
+		{name: "plenty left", limit: &limit{limit: 5000, remaining: 4000, reset: future}},
+		{name: "reset passed", limit: &limit{limit: 5000, remaining: 0, reset: time.Now().Add(-time.Second)}},
+		{name: "exhausted", limit: &limit{limit: 5000, remaining: 10, reset: future}, minWait: 99 * time.Second, maxWait: 101 * time.Second},
+		{name: "last 10%", limit: &limit{limit: 5000, remaining: 110, reset: future}, minWait: 990 * time.Millisecond, maxWait: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := NewRateLimit(nil, 0)
+			if tt.limit != nil {
+				rl.limits["core"] = tt.limit
+			}
+
+			if got := rl.throttle("core"); got < tt.minWait || got > tt.maxWait {
+				t.Errorf("throttle() = %v, want in [%v, %v]", got, tt.minWait, tt.maxWait)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	rl := NewRateLimit(nil, 0)
+	header := make(http.Header)
+	header.Set("X-RateLimit-Limit", "5000")
+	header.Set("X-RateLimit-Remaining", "42")
+	header.Set("X-RateLimit-Reset", "1700000000")
+	header.Set("X-RateLimit-Resource", "search")
+	rl.update("core", header)
+
+	l := rl.limits["search"]
+	if l == nil || l.limit != 5000 || l.remaining != 42 || !l.reset.Equal(time.Unix(1700000000, 0)) {
+		t.Fatalf("limits[search] = %+v", l)
+	}
+
+	if rl.limits["core"] != nil {
+		t.Errorf("limits[core] = %+v, want nil", rl.limits["core"])
+	}
+}
+
+// statusServer answers with the statuses in turn, then with 200
+func statusServer(statuses []int, header http.Header) (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt32(&calls, 1)) - 1
+		for key := range header {
+			w.Header().Set(key, header.Get(key))
+		}
+
+		if i < len(statuses) {
+			w.WriteHeader(statuses[i])
+			return
+		}
+
+		w.Write([]byte("ok"))
+	}))
+
+	return srv, &calls
+}
+
+func TestRoundTrip(t *testing.T) {
+	retryNow := http.Header{"Retry-After": []string{"0"}}
+	tests := []struct {
+		name       string
+		statuses   []int
+		header     http.Header
+		maxRetries int
+		wantStatus int
+		wantCalls  int32
+	}{
+		{name: "no retry needed", wantStatus: 200, wantCalls: 1, maxRetries: 3},
+		{name: "429 retried", statuses: []int{429, 429}, header: retryNow, maxRetries: 3, wantStatus: 200, wantCalls: 3},
+		{name: "403 retry after retried", statuses: []int{403}, header: retryNow, maxRetries: 3, wantStatus: 200, wantCalls: 2},
+		{name: "5xx retried", statuses: []int{502}, maxRetries: 3, wantStatus: 200, wantCalls: 2},
+		{name: "retry cap", statuses: []int{429, 429, 429, 429}, header: retryNow, maxRetries: 2, wantStatus: 429, wantCalls: 3},
+		{name: "not retried", statuses: []int{404}, maxRetries: 3, wantStatus: 404, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls := statusServer(tt.statuses, tt.header)
+			defer srv.Close()
+
+			client := &http.Client{Transport: NewRateLimit(nil, tt.maxRetries)}
+			resp, err := client.Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %v, want %v", resp.StatusCode, tt.wantStatus)
+			}
+
+			if got := atomic.LoadInt32(calls); got != tt.wantCalls {
+				t.Errorf("calls = %v, want %v", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRoundTripCancelledWhileWaiting(t *testing.T) {
+	srv, _ := statusServer([]int{429}, http.Header{"Retry-After": []string{"60"}})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	client := &http.Client{Transport: NewRateLimit(nil, 3)}
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("Do() error = nil, want the context deadline")
+	}
+}